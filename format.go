@@ -0,0 +1,334 @@
+package pipe
+
+import (
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/internal/runner"
+	"pipelined.dev/pipe/meta"
+	"pipelined.dev/pipe/sample"
+)
+
+// format-specific components
+type (
+	// IntPump is a pump that produces signal.Int buffers. IntPump is used
+	// to bind it instead of Pump. It returns the bit depth of samples.
+	IntPump interface {
+		Pump
+		IntPump(pipeID string) (func(signal.Int, *meta.Data) error, signal.SampleRate, int, signal.BitDepth, error)
+	}
+
+	// Float32Pump is a pump that produces sample.Float32 buffers.
+	// Float32Pump is used to bind it instead of Pump.
+	Float32Pump interface {
+		Pump
+		Float32Pump(pipeID string) (func(sample.Float32, *meta.Data) error, signal.SampleRate, int, error)
+	}
+
+	// IntProcessor is a processor that works with signal.Int buffers.
+	// IntProcess is used to bind it instead of Process. It returns the
+	// bit depth of samples that processor expects.
+	IntProcessor interface {
+		Processor
+		IntProcess(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Int, *meta.Data) error, signal.BitDepth, error)
+	}
+
+	// Float32Processor is a processor that works with sample.Float32
+	// buffers. Float32Process is used to bind it instead of Process.
+	Float32Processor interface {
+		Processor
+		Float32Process(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(sample.Float32, *meta.Data) error, error)
+	}
+
+	// IntSink is a sink that consumes signal.Int buffers. IntSink is used
+	// to bind it instead of Sink. It returns the bit depth of samples that
+	// sink expects.
+	IntSink interface {
+		Sink
+		IntSink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Int, *meta.Data) error, signal.BitDepth, error)
+	}
+
+	// Float32Sink is a sink that consumes sample.Float32 buffers.
+	// Float32Sink is used to bind it instead of Sink.
+	Float32Sink interface {
+		Sink
+		Float32Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(sample.Float32, *meta.Data) error, error)
+	}
+)
+
+// interleaved components. Pumps and processors receive pointers to
+// interleaved buffers, so they can trim the size of data.
+type (
+	// InterIntPump is a pump that produces interleaved signal.InterInt
+	// buffers. InterIntPump is used to bind it instead of Pump. It returns
+	// the bit depth of samples. Pump can trim the size of the buffer, but
+	// it must contain the same number of samples for each channel. Pump
+	// can set Unsigned flag of the buffer, unsigned samples are shifted
+	// into signed range when converted for other components.
+	InterIntPump interface {
+		Pump
+		InterIntPump(pipeID string) (func(*signal.InterInt, *meta.Data) error, signal.SampleRate, int, signal.BitDepth, error)
+	}
+
+	// InterFloat64Pump is a pump that produces interleaved
+	// sample.InterFloat64 buffers. InterFloat64Pump is used to bind it
+	// instead of Pump.
+	InterFloat64Pump interface {
+		Pump
+		InterFloat64Pump(pipeID string) (func(*sample.InterFloat64, *meta.Data) error, signal.SampleRate, int, error)
+	}
+
+	// InterIntProcessor is a processor that works with interleaved
+	// signal.InterInt buffers. InterIntProcess is used to bind it instead
+	// of Process. It returns the bit depth of samples that processor
+	// expects.
+	InterIntProcessor interface {
+		Processor
+		InterIntProcess(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(*signal.InterInt, *meta.Data) error, signal.BitDepth, error)
+	}
+
+	// InterFloat64Processor is a processor that works with interleaved
+	// sample.InterFloat64 buffers. InterFloat64Process is used to bind it
+	// instead of Process.
+	InterFloat64Processor interface {
+		Processor
+		InterFloat64Process(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(*sample.InterFloat64, *meta.Data) error, error)
+	}
+
+	// InterIntSink is a sink that consumes interleaved signal.InterInt
+	// buffers. InterIntSink is used to bind it instead of Sink. It returns
+	// the bit depth of samples that sink expects. Buffers converted from
+	// other formats carry signed samples.
+	InterIntSink interface {
+		Sink
+		InterIntSink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.InterInt, *meta.Data) error, signal.BitDepth, error)
+	}
+
+	// InterFloat64Sink is a sink that consumes interleaved
+	// sample.InterFloat64 buffers. InterFloat64Sink is used to bind it
+	// instead of Sink.
+	InterFloat64Sink interface {
+		Sink
+		InterFloat64Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(sample.InterFloat64, *meta.Data) error, error)
+	}
+)
+
+// bindPump binds pump with respect to the format it produces.
+func bindPump(pipeID string, p Pump) (runner.Pump, signal.SampleRate, int, error) {
+	var (
+		r           = runner.Pump{ID: newUID(), Hooks: BindHooks(p)}
+		sampleRate  signal.SampleRate
+		numChannels int
+		err         error
+	)
+	switch v := p.(type) {
+	case InterIntPump:
+		var (
+			fn       func(*signal.InterInt, *meta.Data) error
+			bitDepth signal.BitDepth
+		)
+		if fn, sampleRate, numChannels, bitDepth, err = v.InterIntPump(pipeID); err == nil {
+			r.Format, err = sample.IntFormat(bitDepth)
+			r.Format |= sample.Interleaved
+			r.FormatFn = interIntRefFunc(fn)
+		}
+	case InterFloat64Pump:
+		var fn func(*sample.InterFloat64, *meta.Data) error
+		fn, sampleRate, numChannels, err = v.InterFloat64Pump(pipeID)
+		r.Format, r.FormatFn = sample.FormatFloat64|sample.Interleaved, interFloat64RefFunc(fn)
+	case IntPump:
+		var (
+			fn       func(signal.Int, *meta.Data) error
+			bitDepth signal.BitDepth
+		)
+		if fn, sampleRate, numChannels, bitDepth, err = v.IntPump(pipeID); err == nil {
+			r.Format, err = sample.IntFormat(bitDepth)
+			r.FormatFn = intFunc(fn)
+		}
+	case Float32Pump:
+		var fn func(sample.Float32, *meta.Data) error
+		fn, sampleRate, numChannels, err = v.Float32Pump(pipeID)
+		r.Format, r.FormatFn = sample.FormatFloat32, float32Func(fn)
+	case MetaPump:
+		r.Fn, sampleRate, numChannels, err = v.MetaPump(pipeID)
+	default:
+		var fn func(signal.Float64) error
+		fn, sampleRate, numChannels, err = p.Pump(pipeID)
+		r.Fn = func(b signal.Float64, _ *meta.Data) error {
+			return fn(b)
+		}
+	}
+	if err != nil {
+		return runner.Pump{}, 0, 0, err
+	}
+	return r, sampleRate, numChannels, nil
+}
+
+// bindProcessor binds processor with respect to the format it expects.
+func bindProcessor(pipeID string, p Processor, sampleRate signal.SampleRate, numChannels int) (runner.Processor, error) {
+	var (
+		r   = runner.Processor{ID: newUID(), Hooks: BindHooks(p)}
+		err error
+	)
+	switch v := p.(type) {
+	case InterIntProcessor:
+		var (
+			fn       func(*signal.InterInt, *meta.Data) error
+			bitDepth signal.BitDepth
+		)
+		if fn, bitDepth, err = v.InterIntProcess(pipeID, sampleRate, numChannels); err == nil {
+			r.Format, err = sample.IntFormat(bitDepth)
+			r.Format |= sample.Interleaved
+			r.FormatFn = interIntRefFunc(fn)
+		}
+	case InterFloat64Processor:
+		var fn func(*sample.InterFloat64, *meta.Data) error
+		fn, err = v.InterFloat64Process(pipeID, sampleRate, numChannels)
+		r.Format, r.FormatFn = sample.FormatFloat64|sample.Interleaved, interFloat64RefFunc(fn)
+	case IntProcessor:
+		var (
+			fn       func(signal.Int, *meta.Data) error
+			bitDepth signal.BitDepth
+		)
+		if fn, bitDepth, err = v.IntProcess(pipeID, sampleRate, numChannels); err == nil {
+			r.Format, err = sample.IntFormat(bitDepth)
+			r.FormatFn = intFunc(fn)
+		}
+	case Float32Processor:
+		var fn func(sample.Float32, *meta.Data) error
+		fn, err = v.Float32Process(pipeID, sampleRate, numChannels)
+		r.Format, r.FormatFn = sample.FormatFloat32, float32Func(fn)
+	case ChannelProcessor:
+		r.Channels = make([]runner.ChannelFunc, numChannels)
+		for i := range r.Channels {
+			if r.Channels[i], err = v.ProcessChannel(pipeID, sampleRate, i); err != nil {
+				break
+			}
+		}
+	case MetaProcessor:
+		r.Fn, err = v.MetaProcess(pipeID, sampleRate, numChannels)
+	default:
+		var fn func(signal.Float64) error
+		fn, err = p.Process(pipeID, sampleRate, numChannels)
+		r.Fn = func(b signal.Float64, _ *meta.Data) error {
+			return fn(b)
+		}
+	}
+	if err != nil {
+		return runner.Processor{}, err
+	}
+	r.SkipSilence, r.Tail = silenceTail(pipeID, p)
+	return r, nil
+}
+
+// bindSink binds sink with respect to the format it expects.
+func bindSink(pipeID string, s Sink, sampleRate signal.SampleRate, numChannels int) (runner.Sink, error) {
+	var (
+		r   = runner.Sink{ID: newUID(), Mutable: mutates(s), Hooks: BindHooks(s)}
+		err error
+	)
+	switch v := s.(type) {
+	case InterIntSink:
+		var (
+			fn       func(signal.InterInt, *meta.Data) error
+			bitDepth signal.BitDepth
+		)
+		if fn, bitDepth, err = v.InterIntSink(pipeID, sampleRate, numChannels); err == nil {
+			r.Format, err = sample.IntFormat(bitDepth)
+			r.Format |= sample.Interleaved
+			r.FormatFn = interIntFunc(fn)
+		}
+	case InterFloat64Sink:
+		var fn func(sample.InterFloat64, *meta.Data) error
+		fn, err = v.InterFloat64Sink(pipeID, sampleRate, numChannels)
+		r.Format, r.FormatFn = sample.FormatFloat64|sample.Interleaved, interFloat64Func(fn)
+	case IntSink:
+		var (
+			fn       func(signal.Int, *meta.Data) error
+			bitDepth signal.BitDepth
+		)
+		if fn, bitDepth, err = v.IntSink(pipeID, sampleRate, numChannels); err == nil {
+			r.Format, err = sample.IntFormat(bitDepth)
+			r.FormatFn = intFunc(fn)
+		}
+	case Float32Sink:
+		var fn func(sample.Float32, *meta.Data) error
+		fn, err = v.Float32Sink(pipeID, sampleRate, numChannels)
+		r.Format, r.FormatFn = sample.FormatFloat32, float32Func(fn)
+	case MetaSink:
+		r.Fn, err = v.MetaSink(pipeID, sampleRate, numChannels)
+	default:
+		var fn func(signal.Float64) error
+		fn, err = s.Sink(pipeID, sampleRate, numChannels)
+		r.Fn = func(b signal.Float64, _ *meta.Data) error {
+			return fn(b)
+		}
+	}
+	if err != nil {
+		return runner.Sink{}, err
+	}
+	return r, nil
+}
+
+// silenceTail checks if processor implements SilenceSkipper and if so,
+// returns its tail.
+func silenceTail(pipeID string, p Processor) (bool, int) {
+	if v, ok := p.(SilenceSkipper); ok {
+		return true, v.SilenceTail(pipeID)
+	}
+	return false, 0
+}
+
+// mutates checks if sink implements Mutator and needs a private buffer.
+func mutates(s Sink) bool {
+	if v, ok := s.(Mutator); ok {
+		return v.Mutates()
+	}
+	return false
+}
+
+// intFunc wraps closure of component that works with signal.Int buffers.
+func intFunc(fn func(signal.Int, *meta.Data) error) runner.FormatFunc {
+	return func(m *runner.Message) error {
+		return fn(m.Int, &m.Meta)
+	}
+}
+
+// float32Func wraps closure of component that works with sample.Float32
+// buffers.
+func float32Func(fn func(sample.Float32, *meta.Data) error) runner.FormatFunc {
+	return func(m *runner.Message) error {
+		return fn(m.Float32, &m.Meta)
+	}
+}
+
+// interIntFunc wraps closure of component that works with
+// signal.InterInt buffers.
+func interIntFunc(fn func(signal.InterInt, *meta.Data) error) runner.FormatFunc {
+	return func(m *runner.Message) error {
+		return fn(m.InterInt, &m.Meta)
+	}
+}
+
+// interFloat64Func wraps closure of component that works with
+// sample.InterFloat64 buffers.
+func interFloat64Func(fn func(sample.InterFloat64, *meta.Data) error) runner.FormatFunc {
+	return func(m *runner.Message) error {
+		return fn(m.InterFloat64, &m.Meta)
+	}
+}
+
+// interIntRefFunc wraps closure of component that can change the size of
+// signal.InterInt buffers.
+func interIntRefFunc(fn func(*signal.InterInt, *meta.Data) error) runner.FormatFunc {
+	return func(m *runner.Message) error {
+		return fn(&m.InterInt, &m.Meta)
+	}
+}
+
+// interFloat64RefFunc wraps closure of component that can change the
+// size of sample.InterFloat64 buffers.
+func interFloat64RefFunc(fn func(*sample.InterFloat64, *meta.Data) error) runner.FormatFunc {
+	return func(m *runner.Message) error {
+		return fn(&m.InterFloat64, &m.Meta)
+	}
+}
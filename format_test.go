@@ -0,0 +1,239 @@
+package pipe_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/internal/mock"
+	"pipelined.dev/pipe/metric"
+)
+
+func TestFormats(t *testing.T) {
+	pump := &mock.IntPump{
+		Mock: mock.Pump{
+			Limit:       10*bufferSize + 10,
+			NumChannels: 2,
+			SampleRate:  44100,
+			Value:       0.5,
+		},
+		BitDepth: signal.BitDepth16,
+	}
+	proc1 := &mock.Float32Processor{}
+	proc2 := &mock.Processor{}
+	sink1 := &mock.IntSink{BitDepth: signal.BitDepth16}
+	sink2 := &mock.IntSink{BitDepth: signal.BitDepth24}
+	sink3 := &mock.Float32Sink{}
+	sink4 := &mock.Sink{}
+
+	recorder := metric.NewMemory()
+	l, err := pipe.Config{Recorder: recorder}.New(
+		&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(proc1, proc2),
+			Sinks:      pipe.Sinks(sink1, sink2, sink3, sink4),
+		},
+	)
+	assert.Nil(t, err)
+	err = pipe.Wait(l.Run(context.Background(), bufferSize))
+	assert.Nil(t, err)
+	pipe.Wait(l.Close())
+
+	for _, proc := range []*mock.Processor{&proc1.Processor, proc2} {
+		_, samples := proc.Count()
+		assert.Equal(t, pump.Mock.Limit, samples)
+	}
+	for _, ints := range []struct {
+		buffer   signal.Int
+		expected int
+	}{
+		{buffer: sink1.Ints(), expected: 16383},
+		// signal is quantized by 16-bit pump
+		{buffer: sink2.Ints(), expected: 16383 * (1<<23 - 1) / (1<<15 - 1)},
+	} {
+		assert.Equal(t, 2, len(ints.buffer))
+		for i := range ints.buffer {
+			assert.Equal(t, pump.Mock.Limit, len(ints.buffer[i]))
+			for _, v := range ints.buffer[i] {
+				assert.InDelta(t, ints.expected, v, 1)
+			}
+		}
+	}
+	floats := sink3.Floats()
+	assert.Equal(t, pump.Mock.Limit, floats.Size())
+	for i := range floats {
+		assert.InDeltaSlice(t, sink4.Buffer()[i], floats[i], 0.0001)
+		for _, v := range sink4.Buffer()[i] {
+			assert.InDelta(t, pump.Mock.Value, v, 0.0001)
+		}
+	}
+}
+
+func TestIntLine(t *testing.T) {
+	pump := &mock.IntPump{
+		Mock: mock.Pump{
+			Limit:       10 * bufferSize,
+			NumChannels: 1,
+			Value:       0.25,
+		},
+		BitDepth: signal.BitDepth32,
+	}
+	sink := &mock.IntSink{BitDepth: signal.BitDepth32}
+	l, err := pipe.New(
+		&pipe.Line{
+			Pump:  pump,
+			Sinks: pipe.Sinks(sink),
+		},
+	)
+	assert.Nil(t, err)
+	err = pipe.Wait(l.Run(context.Background(), bufferSize))
+	assert.Nil(t, err)
+	pipe.Wait(l.Close())
+
+	// values are not converted
+	expected := int(pump.Mock.Value * float64(1<<31-1))
+	for _, v := range sink.Ints()[0] {
+		assert.Equal(t, expected, v)
+	}
+
+	// unsupported bit depth
+	_, err = pipe.New(
+		&pipe.Line{
+			Pump:  &mock.IntPump{BitDepth: 12},
+			Sinks: pipe.Sinks(sink),
+		},
+	)
+	assert.Error(t, err)
+}
+
+func TestInterleaved(t *testing.T) {
+	pump := &mock.InterIntPump{
+		Mock: mock.Pump{
+			Limit:       10*bufferSize + 10,
+			NumChannels: 2,
+			SampleRate:  44100,
+			Value:       0.5,
+		},
+		BitDepth: signal.BitDepth16,
+	}
+	proc1 := &mock.InterFloat64Processor{}
+	proc2 := &mock.Processor{}
+	sink1 := &mock.InterIntSink{BitDepth: signal.BitDepth16}
+	sink2 := &mock.IntSink{BitDepth: signal.BitDepth16}
+	sink3 := &mock.Sink{}
+
+	l, err := pipe.New(
+		&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(proc1, proc2),
+			Sinks:      pipe.Sinks(sink1, sink2, sink3),
+		},
+	)
+	assert.Nil(t, err)
+	err = pipe.Wait(l.Run(context.Background(), bufferSize))
+	assert.Nil(t, err)
+	pipe.Wait(l.Close())
+
+	for _, proc := range []*mock.Processor{&proc1.Processor, proc2} {
+		_, samples := proc.Count()
+		assert.Equal(t, pump.Mock.Limit, samples)
+	}
+	assert.Equal(t, 2*pump.Mock.Limit, len(sink1.Ints()))
+	for _, v := range sink1.Ints() {
+		assert.InDelta(t, 16383, v, 1)
+	}
+	for i := range sink2.Ints() {
+		assert.Equal(t, pump.Mock.Limit, len(sink2.Ints()[i]))
+		for _, v := range sink2.Ints()[i] {
+			assert.InDelta(t, 16383, v, 1)
+		}
+	}
+	for i := range sink3.Buffer() {
+		for _, v := range sink3.Buffer()[i] {
+			assert.InDelta(t, pump.Mock.Value, v, 0.0001)
+		}
+	}
+}
+
+func TestInterleavedUnsigned(t *testing.T) {
+	pump := &mock.InterIntPump{
+		Mock: mock.Pump{
+			Limit:       10*bufferSize + 10,
+			NumChannels: 2,
+			SampleRate:  44100,
+			Value:       0.5,
+		},
+		BitDepth: signal.BitDepth8,
+		Unsigned: true,
+	}
+	proc := &mock.InterFloat64Processor{}
+	sink1 := &mock.InterIntSink{BitDepth: signal.BitDepth8}
+	sink2 := &mock.IntSink{BitDepth: signal.BitDepth8}
+	sink3 := &mock.Sink{}
+	sink4 := &mock.Sink{}
+
+	recorder := metric.NewMemory()
+	l, err := pipe.Config{Recorder: recorder}.New(
+		&pipe.Line{
+			Pump:  pump,
+			Sinks: pipe.Sinks(sink1, sink2, sink3),
+		},
+		&pipe.Line{
+			Pump: &mock.InterIntPump{
+				Mock: mock.Pump{
+					Limit:       pump.Mock.Limit,
+					NumChannels: 2,
+					SampleRate:  44100,
+					Value:       0.5,
+				},
+				BitDepth: signal.BitDepth8,
+				Unsigned: true,
+			},
+			Processors: pipe.Processors(proc),
+			Sinks:      pipe.Sinks(sink4),
+		},
+	)
+	assert.Nil(t, err)
+	pumpID, _ := l.ComponentID(pump)
+	interval := signal.SampleRate(44100).DurationOf(bufferSize)
+	err = pipe.Wait(l.Run(context.Background(), bufferSize, pipe.WithLevels(interval)))
+	assert.Nil(t, err)
+
+	// levels of unsigned values are measured around zero
+	levels := recorder.Levels(pumpID)
+	assert.Equal(t, 10, len(levels))
+	for _, channels := range levels {
+		for _, level := range channels {
+			assert.InDelta(t, 63.0/127, level.DC, 1e-9)
+			assert.InDelta(t, 63.0/127, level.Peak, 1e-9)
+		}
+	}
+	pipe.Wait(l.Close())
+
+	// unsigned values are passed as is
+	assert.Equal(t, 2*pump.Mock.Limit, len(sink1.Ints()))
+	for _, v := range sink1.Ints() {
+		assert.Equal(t, 63+127, v)
+	}
+	// converted values are not shifted
+	for i := range sink2.Ints() {
+		assert.Equal(t, pump.Mock.Limit, len(sink2.Ints()[i]))
+		for _, v := range sink2.Ints()[i] {
+			assert.Equal(t, 63, v)
+		}
+	}
+	for _, sink := range []*mock.Sink{sink3, sink4} {
+		assert.Equal(t, pump.Mock.Limit, sink.Buffer().Size())
+		for i := range sink.Buffer() {
+			for _, v := range sink.Buffer()[i] {
+				assert.InDelta(t, pump.Mock.Value, v, 0.01)
+			}
+		}
+	}
+	_, samples := proc.Count()
+	assert.Equal(t, pump.Mock.Limit, samples)
+}
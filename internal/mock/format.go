@@ -0,0 +1,177 @@
+package mock
+
+import (
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/meta"
+	"pipelined.dev/pipe/sample"
+)
+
+// IntPump mocks a pipe.IntPump interface. It's configured by Mock pump,
+// Value is scaled with respect to the bit depth.
+type IntPump struct {
+	Mock     Pump
+	BitDepth signal.BitDepth
+}
+
+// IntPump returns new signal.Int buffer for pipe.
+func (m *IntPump) IntPump(sourceID string) (func(signal.Int, *meta.Data) error, signal.SampleRate, int, signal.BitDepth, error) {
+	value := int(m.Mock.Value * float64(int(1)<<(m.BitDepth-1)-1))
+	return func(b signal.Int, d *meta.Data) error {
+		bs, err := m.Mock.next(sample.IntSize(b))
+		if err != nil {
+			return err
+		}
+		for i := range b {
+			b[i] = b[i][:bs]
+			for j := range b[i] {
+				b[i][j] = value
+			}
+		}
+		m.Mock.annotate(d)
+		m.Mock.advance(bs)
+		return nil
+	}, m.Mock.SampleRate, m.Mock.NumChannels, m.BitDepth, nil
+}
+
+// Pump implements pipe.Pump.
+func (m *IntPump) Pump(sourceID string) (func(signal.Float64) error, signal.SampleRate, int, error) {
+	return m.Mock.Pump(sourceID)
+}
+
+// Reset implements pipe.Resetter.
+func (m *IntPump) Reset(pipeID string) error {
+	return m.Mock.Reset(pipeID)
+}
+
+// Interrupt implements pipe.Interrupter.
+func (m *IntPump) Interrupt(pipeID string) error {
+	return m.Mock.Interrupt(pipeID)
+}
+
+// Flush implements pipe.Flusher.
+func (m *IntPump) Flush(pipeID string) error {
+	return m.Mock.Flush(pipeID)
+}
+
+// Float32Processor mocks a pipe.Float32Processor interface.
+type Float32Processor struct {
+	Processor
+}
+
+// Float32Process implementation for runner.
+func (m *Float32Processor) Float32Process(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(sample.Float32, *meta.Data) error, error) {
+	return func(b sample.Float32, _ *meta.Data) error {
+		if m.ErrorOnCall != nil {
+			return m.ErrorOnCall
+		}
+		m.advance(b.Size())
+		return nil
+	}, nil
+}
+
+// IntSink mocks a pipe.IntSink interface. It's configured by Mock sink.
+// Buffer is not thread-safe, so should not be checked while pipe is running.
+type IntSink struct {
+	Mock     Sink
+	BitDepth signal.BitDepth
+	ints     signal.Int
+}
+
+// IntSink implementation for runner.
+func (m *IntSink) IntSink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Int, *meta.Data) error, signal.BitDepth, error) {
+	return func(b signal.Int, _ *meta.Data) error {
+		if m.Mock.ErrorOnCall != nil {
+			return m.Mock.ErrorOnCall
+		}
+		if !m.Mock.Discard {
+			if m.ints == nil {
+				m.ints = make(signal.Int, len(b))
+			}
+			for i := range b {
+				m.ints[i] = append(m.ints[i], b[i]...)
+			}
+		}
+		m.Mock.advance(sample.IntSize(b))
+		return nil
+	}, m.BitDepth, nil
+}
+
+// Sink implements pipe.Sink.
+func (m *IntSink) Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error) {
+	return m.Mock.Sink(pipeID, sampleRate, numChannels)
+}
+
+// Reset implements pipe.Resetter.
+func (m *IntSink) Reset(pipeID string) error {
+	m.ints = nil
+	return m.Mock.Reset(pipeID)
+}
+
+// Interrupt implements pipe.Interrupter.
+func (m *IntSink) Interrupt(pipeID string) error {
+	return m.Mock.Interrupt(pipeID)
+}
+
+// Flush implements pipe.Flusher.
+func (m *IntSink) Flush(pipeID string) error {
+	return m.Mock.Flush(pipeID)
+}
+
+// Ints returns sink's buffer.
+func (m *IntSink) Ints() signal.Int {
+	return m.ints
+}
+
+// Float32Sink mocks a pipe.Float32Sink interface. It's configured by
+// Mock sink.
+// Buffer is not thread-safe, so should not be checked while pipe is running.
+type Float32Sink struct {
+	Mock   Sink
+	floats sample.Float32
+}
+
+// Float32Sink implementation for runner.
+func (m *Float32Sink) Float32Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(sample.Float32, *meta.Data) error, error) {
+	return func(b sample.Float32, _ *meta.Data) error {
+		if m.Mock.ErrorOnCall != nil {
+			return m.Mock.ErrorOnCall
+		}
+		if !m.Mock.Discard {
+			if m.floats == nil {
+				m.floats = make(sample.Float32, len(b))
+			}
+			for i := range b {
+				m.floats[i] = append(m.floats[i], b[i]...)
+			}
+		}
+		m.Mock.advance(b.Size())
+		return nil
+	}, nil
+}
+
+// Sink implements pipe.Sink.
+func (m *Float32Sink) Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error) {
+	return m.Mock.Sink(pipeID, sampleRate, numChannels)
+}
+
+// Reset implements pipe.Resetter.
+func (m *Float32Sink) Reset(pipeID string) error {
+	m.floats = nil
+	return m.Mock.Reset(pipeID)
+}
+
+// Interrupt implements pipe.Interrupter.
+func (m *Float32Sink) Interrupt(pipeID string) error {
+	return m.Mock.Interrupt(pipeID)
+}
+
+// Flush implements pipe.Flusher.
+func (m *Float32Sink) Flush(pipeID string) error {
+	return m.Mock.Flush(pipeID)
+}
+
+// Floats returns sink's buffer.
+func (m *Float32Sink) Floats() sample.Float32 {
+	return m.floats
+}
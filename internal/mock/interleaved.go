@@ -0,0 +1,125 @@
+package mock
+
+import (
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/meta"
+	"pipelined.dev/pipe/sample"
+)
+
+// InterIntPump mocks a pipe.InterIntPump interface. It's configured by
+// Mock pump, Value is scaled with respect to the bit depth. If Unsigned
+// is set, values are shifted into unsigned range.
+type InterIntPump struct {
+	Mock     Pump
+	BitDepth signal.BitDepth
+	Unsigned bool
+}
+
+// InterIntPump returns new signal.InterInt buffer for pipe.
+func (m *InterIntPump) InterIntPump(sourceID string) (func(*signal.InterInt, *meta.Data) error, signal.SampleRate, int, signal.BitDepth, error) {
+	res := int(1)<<(m.BitDepth-1) - 1
+	value := int(m.Mock.Value * float64(res))
+	if m.Unsigned {
+		value += res
+	}
+	return func(b *signal.InterInt, d *meta.Data) error {
+		bs, err := m.Mock.next(b.Size())
+		if err != nil {
+			return err
+		}
+		b.Data = b.Data[:bs*b.NumChannels]
+		b.Unsigned = m.Unsigned
+		for i := range b.Data {
+			b.Data[i] = value
+		}
+		m.Mock.annotate(d)
+		m.Mock.advance(bs)
+		return nil
+	}, m.Mock.SampleRate, m.Mock.NumChannels, m.BitDepth, nil
+}
+
+// Pump implements pipe.Pump.
+func (m *InterIntPump) Pump(sourceID string) (func(signal.Float64) error, signal.SampleRate, int, error) {
+	return m.Mock.Pump(sourceID)
+}
+
+// Reset implements pipe.Resetter.
+func (m *InterIntPump) Reset(pipeID string) error {
+	return m.Mock.Reset(pipeID)
+}
+
+// Interrupt implements pipe.Interrupter.
+func (m *InterIntPump) Interrupt(pipeID string) error {
+	return m.Mock.Interrupt(pipeID)
+}
+
+// Flush implements pipe.Flusher.
+func (m *InterIntPump) Flush(pipeID string) error {
+	return m.Mock.Flush(pipeID)
+}
+
+// InterFloat64Processor mocks a pipe.InterFloat64Processor interface.
+type InterFloat64Processor struct {
+	Processor
+}
+
+// InterFloat64Process implementation for runner.
+func (m *InterFloat64Processor) InterFloat64Process(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(*sample.InterFloat64, *meta.Data) error, error) {
+	return func(b *sample.InterFloat64, _ *meta.Data) error {
+		if m.ErrorOnCall != nil {
+			return m.ErrorOnCall
+		}
+		m.advance(b.Size())
+		return nil
+	}, nil
+}
+
+// InterIntSink mocks a pipe.InterIntSink interface. It's configured by
+// Mock sink.
+// Buffer is not thread-safe, so should not be checked while pipe is running.
+type InterIntSink struct {
+	Mock     Sink
+	BitDepth signal.BitDepth
+	ints     []int
+}
+
+// InterIntSink implementation for runner.
+func (m *InterIntSink) InterIntSink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.InterInt, *meta.Data) error, signal.BitDepth, error) {
+	return func(b signal.InterInt, _ *meta.Data) error {
+		if m.Mock.ErrorOnCall != nil {
+			return m.Mock.ErrorOnCall
+		}
+		if !m.Mock.Discard {
+			m.ints = append(m.ints, b.Data...)
+		}
+		m.Mock.advance(b.Size())
+		return nil
+	}, m.BitDepth, nil
+}
+
+// Sink implements pipe.Sink.
+func (m *InterIntSink) Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error) {
+	return m.Mock.Sink(pipeID, sampleRate, numChannels)
+}
+
+// Reset implements pipe.Resetter.
+func (m *InterIntSink) Reset(pipeID string) error {
+	m.ints = nil
+	return m.Mock.Reset(pipeID)
+}
+
+// Interrupt implements pipe.Interrupter.
+func (m *InterIntSink) Interrupt(pipeID string) error {
+	return m.Mock.Interrupt(pipeID)
+}
+
+// Flush implements pipe.Flusher.
+func (m *InterIntSink) Flush(pipeID string) error {
+	return m.Mock.Flush(pipeID)
+}
+
+// Ints returns interleaved samples received by sink.
+func (m *InterIntSink) Ints() []int {
+	return m.ints
+}
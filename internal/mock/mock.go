@@ -6,6 +6,9 @@ import (
 	"time"
 
 	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/meta"
 )
 
 // Pump mocks a pipe.Pump interface.
@@ -16,6 +19,14 @@ type Pump struct {
 	Value       float64
 	NumChannels int
 	SampleRate  signal.SampleRate
+	// ChannelLayout is reported by pump if set.
+	ChannelLayout pipe.ChannelLayout
+	// Tags are attached to each buffer.
+	Tags meta.Tags
+	// Events are added to each buffer.
+	Events []meta.Event
+	// Silent pump doesn't fill buffers and marks them as silent.
+	Silent      bool
 	ErrorOnCall error
 	Hooks
 }
@@ -43,34 +54,73 @@ func (m *Pump) ValueParam(v float64) func() {
 
 // Pump returns new buffer for pipe.
 func (m *Pump) Pump(sourceID string) (func(b signal.Float64) error, signal.SampleRate, int, error) {
+	fn, sampleRate, numChannels, err := m.MetaPump(sourceID)
 	return func(b signal.Float64) error {
-		if m.ErrorOnCall != nil {
-			return m.ErrorOnCall
-		}
-
-		if m.samples >= m.Limit {
-			return io.EOF
-		}
-		time.Sleep(m.Interval)
+		return fn(b, nil)
+	}, sampleRate, numChannels, err
+}
 
-		// calculate buffer size.
-		bs := b.Size()
-		// check if we need a shorter.
-		if left := m.Limit - m.samples; left < bs {
-			bs = left
+// MetaPump implements pipe.MetaPump. It attaches pump tags to buffers.
+func (m *Pump) MetaPump(sourceID string) (func(signal.Float64, *meta.Data) error, signal.SampleRate, int, error) {
+	return func(b signal.Float64, d *meta.Data) error {
+		bs, err := m.next(b.Size())
+		if err != nil {
+			return err
 		}
 		for i := range b {
 			// resize buffer
 			b[i] = b[i][:bs]
+			if m.Silent {
+				continue
+			}
 			for j := range b[i] {
 				b[i][j] = m.Value
 			}
 		}
+		m.annotate(d)
 		m.advance(bs)
 		return nil
 	}, m.SampleRate, m.NumChannels, nil
 }
 
+// next returns the size of the next buffer. Error is returned if pump
+// has failed or reached the limit.
+func (m *Pump) next(bufferSize int) (int, error) {
+	if m.ErrorOnCall != nil {
+		return 0, m.ErrorOnCall
+	}
+
+	if m.samples >= m.Limit {
+		return 0, io.EOF
+	}
+	time.Sleep(m.Interval)
+
+	// check if we need a shorter.
+	if left := m.Limit - m.samples; left < bufferSize {
+		return left, nil
+	}
+	return bufferSize, nil
+}
+
+// annotate attaches tags and events to the buffer metadata.
+func (m *Pump) annotate(d *meta.Data) {
+	if d == nil {
+		return
+	}
+	d.Silent = m.Silent
+	for k, v := range m.Tags {
+		d.SetTag(k, v)
+	}
+	for _, e := range m.Events {
+		d.AddEvent(e.Offset, e.Value)
+	}
+}
+
+// Layout implements pipe.LayoutReporter.
+func (m *Pump) Layout(string) pipe.ChannelLayout {
+	return m.ChannelLayout
+}
+
 // Reset implements pipe.Resetter.
 func (m *Pump) Reset(string) error {
 	m.Resetted = true
@@ -96,16 +146,32 @@ func (m *Pump) Flush(string) error {
 // Processor mocks a pipe.Processor interface.
 type Processor struct {
 	counter
+	layout
+	// Events are added to each buffer.
+	Events      []meta.Event
 	ErrorOnCall error
 	Hooks
 }
 
 // Process implementation for runner
 func (m *Processor) Process(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error) {
+	fn, err := m.MetaProcess(pipeID, sampleRate, numChannels)
 	return func(b signal.Float64) error {
+		return fn(b, nil)
+	}, err
+}
+
+// MetaProcess implements pipe.MetaProcessor.
+func (m *Processor) MetaProcess(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64, *meta.Data) error, error) {
+	return func(b signal.Float64, d *meta.Data) error {
 		if m.ErrorOnCall != nil {
 			return m.ErrorOnCall
 		}
+		if d != nil {
+			for _, e := range m.Events {
+				d.AddEvent(e.Offset, e.Value)
+			}
+		}
 		m.advance(b.Size())
 		return nil
 	}, nil
@@ -133,34 +199,161 @@ func (m *Processor) Flush(string) error {
 	return m.ErrorOnFlush
 }
 
+// SilenceSkipper mocks a pipe.SilenceSkipper interface.
+type SilenceSkipper struct {
+	Processor
+	Tail int
+}
+
+// SilenceTail implements pipe.SilenceSkipper.
+func (m *SilenceSkipper) SilenceTail(string) int {
+	return m.Tail
+}
+
+// ChannelProcessor mocks a pipe.ChannelProcessor interface. It
+// multiplies samples of each channel by Gain. Processed samples are
+// counted per channel instead of processor counter.
+type ChannelProcessor struct {
+	Processor
+	Gain     float64
+	channels []int
+}
+
+// ProcessChannel implements pipe.ChannelProcessor.
+func (m *ChannelProcessor) ProcessChannel(pipeID string, sampleRate signal.SampleRate, channel int) (func([]float64) error, error) {
+	for len(m.channels) <= channel {
+		m.channels = append(m.channels, 0)
+	}
+	m.channels[channel] = 0
+	return func(data []float64) error {
+		if m.ErrorOnCall != nil {
+			return m.ErrorOnCall
+		}
+		for i := range data {
+			data[i] *= m.Gain
+		}
+		// each channel updates its own counter
+		m.channels[channel] += len(data)
+		return nil
+	}, nil
+}
+
+// ChannelSamples returns the number of processed samples per channel.
+func (m *ChannelProcessor) ChannelSamples() []int {
+	return m.channels
+}
+
+// Resampler mocks a pipe.Resampler interface.
+// It resamples the signal with nearest-neighbour interpolation.
+type Resampler struct {
+	Processor
+	OutputSampleRate signal.SampleRate
+}
+
+// Resample implementation for runner.
+func (m *Resampler) Resample(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(in, out signal.Float64) error, signal.SampleRate, error) {
+	return func(in, out signal.Float64) error {
+		if m.ErrorOnCall != nil {
+			return m.ErrorOnCall
+		}
+		inSize, outSize := in.Size(), out.Size()
+		for i := range out {
+			for j := range out[i] {
+				out[i][j] = in[i][j*inSize/outSize]
+			}
+		}
+		m.advance(outSize)
+		return nil
+	}, m.OutputSampleRate, nil
+}
+
+// Remixer mocks a pipe.Remixer interface.
+// Each output channel is a copy of input channel with index modulo
+// number of input channels.
+type Remixer struct {
+	Processor
+	OutputNumChannels int
+	// OutputLayout is reported by remixer if set.
+	OutputLayout pipe.ChannelLayout
+}
+
+// Remix implementation for runner.
+func (m *Remixer) Remix(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(in, out signal.Float64) error, int, error) {
+	return func(in, out signal.Float64) error {
+		if m.ErrorOnCall != nil {
+			return m.ErrorOnCall
+		}
+		for i := range out {
+			copy(out[i], in[i%len(in)])
+		}
+		m.advance(out.Size())
+		return nil
+	}, m.OutputNumChannels, nil
+}
+
+// Layout implements pipe.LayoutReporter.
+func (m *Remixer) Layout(string) pipe.ChannelLayout {
+	return m.OutputLayout
+}
+
 // Sink mocks up a pipe.Sink interface.
 // Buffer is not thread-safe, so should not be checked while pipe is running.
 type Sink struct {
 	counter
+	layout
 	buffer      signal.Float64
+	meta        []meta.Data
 	Discard     bool
 	ErrorOnCall error
+	// Mutate negates the content of buffer after it's received.
+	Mutate bool
+	// Mutable is reported by sink as pipe.Mutator.
+	Mutable bool
 	Hooks
 }
 
 // Sink implementation for runner.
 func (m *Sink) Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error) {
+	fn, err := m.MetaSink(pipeID, sampleRate, numChannels)
 	return func(b signal.Float64) error {
+		return fn(b, nil)
+	}, err
+}
+
+// MetaSink implements pipe.MetaSink. It stores metadata of buffers.
+func (m *Sink) MetaSink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64, *meta.Data) error, error) {
+	return func(b signal.Float64, d *meta.Data) error {
 		if m.ErrorOnCall != nil {
 			return m.ErrorOnCall
 		}
 		if !m.Discard {
 			m.buffer = signal.Float64(m.buffer).Append(b)
+			if d != nil {
+				m.meta = append(m.meta, *d)
+			}
+		}
+		if m.Mutate {
+			for i := range b {
+				for j := range b[i] {
+					b[i][j] = -b[i][j]
+				}
+			}
 		}
 		m.advance(b.Size())
 		return nil
 	}, nil
 }
 
+// Mutates implements pipe.Mutator.
+func (m *Sink) Mutates() bool {
+	return m.Mutable
+}
+
 // Reset implements pipe.Resetter.
 func (m *Sink) Reset(string) error {
 	m.Resetted = true
 	m.buffer = nil
+	m.meta = nil
 	m.reset()
 	return m.ErrorOnReset
 }
@@ -193,6 +386,27 @@ func (m *Sink) Buffer() signal.Float64 {
 	return m.buffer
 }
 
+// Meta returns metadata of buffers received by sink.
+func (m *Sink) Meta() []meta.Data {
+	return m.meta
+}
+
+// layout stores the received channel layout.
+type layout struct {
+	channelLayout pipe.ChannelLayout
+}
+
+// ReceiveLayout implements pipe.LayoutReceiver.
+func (l *layout) ReceiveLayout(pipeID string, cl pipe.ChannelLayout) error {
+	l.channelLayout = cl
+	return nil
+}
+
+// ReceivedLayout returns the layout received by component.
+func (l *layout) ReceivedLayout() pipe.ChannelLayout {
+	return l.channelLayout
+}
+
 // Reset resets counter's metrics.
 func (c *counter) reset() {
 	c.messages, c.samples = 0, 0
@@ -171,6 +171,102 @@ func TestProcessor(t *testing.T) {
 	}
 }
 
+func TestResampler(t *testing.T) {
+	tests := []struct {
+		sampleRate       signal.SampleRate
+		outputSampleRate signal.SampleRate
+		in               signal.Float64
+		expected         signal.Float64
+		errorOnCall      error
+	}{
+		{
+			sampleRate:       22050,
+			outputSampleRate: 44100,
+			in:               [][]float64{{1, 2}, {3, 4}},
+			expected:         [][]float64{{1, 1, 2, 2}, {3, 3, 4, 4}},
+		},
+		{
+			sampleRate:       44100,
+			outputSampleRate: 22050,
+			in:               [][]float64{{1, 2, 3, 4}},
+			expected:         [][]float64{{1, 3}},
+		},
+		{
+			errorOnCall: testError,
+		},
+	}
+
+	for _, test := range tests {
+		resampler := &mock.Resampler{
+			Processor: mock.Processor{
+				ErrorOnCall: test.errorOnCall,
+			},
+			OutputSampleRate: test.outputSampleRate,
+		}
+		fn, sampleRate, err := resampler.Resample("", test.sampleRate, test.in.NumChannels())
+		assert.NotNil(t, fn)
+		assert.Equal(t, test.outputSampleRate, sampleRate)
+		assert.Nil(t, err)
+
+		out := signal.Float64Buffer(test.expected.NumChannels(), test.expected.Size())
+		err = fn(test.in, out)
+		assert.Equal(t, test.errorOnCall, err)
+		if test.errorOnCall != nil {
+			continue
+		}
+		assert.Equal(t, test.expected, out)
+
+		messages, samples := resampler.Count()
+		assert.Equal(t, 1, messages)
+		assert.Equal(t, test.expected.Size(), samples)
+	}
+}
+
+func TestRemixer(t *testing.T) {
+	tests := []struct {
+		in          signal.Float64
+		expected    signal.Float64
+		errorOnCall error
+	}{
+		{
+			in:       [][]float64{{1, 2}},
+			expected: [][]float64{{1, 2}, {1, 2}},
+		},
+		{
+			in:       [][]float64{{1, 2}, {3, 4}, {5, 6}},
+			expected: [][]float64{{1, 2}, {3, 4}},
+		},
+		{
+			errorOnCall: testError,
+		},
+	}
+
+	for _, test := range tests {
+		remixer := &mock.Remixer{
+			Processor: mock.Processor{
+				ErrorOnCall: test.errorOnCall,
+			},
+			OutputNumChannels: test.expected.NumChannels(),
+		}
+		fn, numChannels, err := remixer.Remix("", 44100, test.in.NumChannels())
+		assert.NotNil(t, fn)
+		assert.Equal(t, test.expected.NumChannels(), numChannels)
+		assert.Nil(t, err)
+
+		out := signal.Float64Buffer(test.expected.NumChannels(), test.expected.Size())
+		err = fn(test.in, out)
+		assert.Equal(t, test.errorOnCall, err)
+		if test.errorOnCall != nil {
+			continue
+		}
+		assert.Equal(t, test.expected, out)
+
+		messages, samples := remixer.Count()
+		assert.Equal(t, 1, messages)
+		assert.Equal(t, test.expected.Size(), samples)
+	}
+}
+
 func TestSink(t *testing.T) {
 	tests := []struct {
 		sink     *mock.Sink
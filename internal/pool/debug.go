@@ -0,0 +1,119 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrLeak is returned when buffers are not released to the pool.
+	ErrLeak = errors.New("buffers leaked")
+	// ErrUseAfterFree is returned when released buffer was modified.
+	ErrUseAfterFree = errors.New("buffer used after free")
+	// ErrDoubleFree is returned when buffer is released twice.
+	ErrDoubleFree = errors.New("buffer freed twice")
+)
+
+// Tracker records buffers allocated by pools in debug mode. Released
+// buffers are poisoned and checked when they are allocated again, so
+// writes into released buffers are detected. Tracked pools use size
+// classes of their tracker, so buffers of concurrent runs are not mixed.
+type Tracker struct {
+	sync.Mutex
+	classes     sync.Map               // shape to size class of tracked pools
+	outstanding map[interface{}]string // buffer to allocating chain
+	freed       map[interface{}]string // buffer to releasing chain
+	err         error                  // first detected misuse
+}
+
+// NewTracker returns a new buffer tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		outstanding: make(map[interface{}]string),
+		freed:       make(map[interface{}]string),
+	}
+}
+
+// WithTracker returns a copy of pool that records its buffers in
+// provided tracker. Owner identifies the allocating chain. Tracked
+// pools share buffers only with pools of the same tracker.
+func (p Pool) WithTracker(t *Tracker, owner string) Pool {
+	p.tracker = t
+	p.owner = owner
+	p.class = load(&t.classes, shape{
+		numChannels: p.numChannels,
+		capacity:    p.capacity,
+	})
+	return p
+}
+
+// Err returns the first detected misuse of buffers.
+func (t *Tracker) Err() error {
+	t.Lock()
+	defer t.Unlock()
+	return t.err
+}
+
+// Leaks returns an error if any tracked buffers are outstanding. It
+// reports the number of leaked buffers for each allocating chain.
+func (t *Tracker) Leaks() error {
+	t.Lock()
+	defer t.Unlock()
+	if len(t.outstanding) == 0 {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, owner := range t.outstanding {
+		counts[owner]++
+	}
+	chains := make([]string, 0, len(counts))
+	for owner, n := range counts {
+		chains = append(chains, fmt.Sprintf("chain %s: %d", owner, n))
+	}
+	sort.Strings(chains)
+	return fmt.Errorf("%w: %s", ErrLeak, strings.Join(chains, ", "))
+}
+
+// alloc records allocated buffer. Intact is false if released buffer
+// was modified.
+func (t *Tracker) alloc(owner string, key interface{}, intact bool) {
+	if key == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+	if !intact && t.err == nil {
+		if freedBy, ok := t.freed[key]; ok {
+			owner = freedBy
+		}
+		t.err = fmt.Errorf("chain %s: %w", owner, ErrUseAfterFree)
+	}
+	delete(t.freed, key)
+	t.outstanding[key] = owner
+}
+
+// free records released buffer. It returns false if buffer was already
+// released and must not be returned to the pool again.
+func (t *Tracker) free(key interface{}) bool {
+	if key == nil {
+		return true
+	}
+	t.Lock()
+	defer t.Unlock()
+	if owner, ok := t.outstanding[key]; ok {
+		delete(t.outstanding, key)
+		t.freed[key] = owner
+		return true
+	}
+	if owner, ok := t.freed[key]; ok {
+		if t.err == nil {
+			t.err = fmt.Errorf("chain %s: %w", owner, ErrDoubleFree)
+		}
+		return false
+	}
+	// buffer wasn't allocated by tracked pool
+	return true
+}
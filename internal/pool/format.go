@@ -0,0 +1,265 @@
+package pool
+
+import (
+	"math"
+
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/sample"
+)
+
+// kind is the format of pooled buffers.
+type kind int
+
+const (
+	float64Kind kind = iota
+	float32Kind
+	intKind
+	interFloat64Kind
+	interIntKind
+	numKinds
+)
+
+// intPoison is written into released int buffers in debug mode.
+const intPoison = math.MinInt32
+
+// buffer holds a pooled buffer. Only the field of its kind is set.
+type buffer struct {
+	float64      signal.Float64
+	float32      sample.Float32
+	int          signal.Int
+	interFloat64 sample.InterFloat64
+	interInt     signal.InterInt
+}
+
+// ops are operations that differ between kinds of buffers. Released
+// buffers are filled with zeros or with poison in debug mode.
+type ops struct {
+	make     func(numChannels, capacity int) buffer
+	key      func(buffer) interface{} // address of the first sample, nil if buffer is empty
+	fits     func(b buffer, numChannels, capacity int) bool
+	resize   func(b buffer, size int) buffer
+	fill     func(b buffer, poison bool)
+	poisoned func(buffer) bool
+}
+
+// formats are operations of each kind.
+var formats = [numKinds]ops{
+	float64Kind: {
+		make: func(numChannels, capacity int) buffer {
+			return buffer{float64: signal.Float64Buffer(numChannels, capacity)}
+		},
+		key: func(b buffer) interface{} {
+			if len(b.float64) == 0 || cap(b.float64[0]) == 0 {
+				return nil
+			}
+			return &b.float64[0][:1][0]
+		},
+		fits: func(b buffer, numChannels, capacity int) bool {
+			if len(b.float64) != numChannels {
+				return false
+			}
+			for i := range b.float64 {
+				if cap(b.float64[i]) != capacity {
+					return false
+				}
+			}
+			return true
+		},
+		resize: func(b buffer, size int) buffer {
+			for i := range b.float64 {
+				b.float64[i] = b.float64[i][:size]
+			}
+			return b
+		},
+		fill: func(b buffer, poison bool) {
+			v := 0.0
+			if poison {
+				v = math.NaN()
+			}
+			for i := range b.float64 {
+				for j := range b.float64[i] {
+					b.float64[i][j] = v
+				}
+			}
+		},
+		poisoned: func(b buffer) bool {
+			for i := range b.float64 {
+				for _, v := range b.float64[i] {
+					if !math.IsNaN(v) {
+						return false
+					}
+				}
+			}
+			return true
+		},
+	},
+	float32Kind: {
+		make: func(numChannels, capacity int) buffer {
+			return buffer{float32: sample.Float32Buffer(numChannels, capacity)}
+		},
+		key: func(b buffer) interface{} {
+			if len(b.float32) == 0 || cap(b.float32[0]) == 0 {
+				return nil
+			}
+			return &b.float32[0][:1][0]
+		},
+		fits: func(b buffer, numChannels, capacity int) bool {
+			if len(b.float32) != numChannels {
+				return false
+			}
+			for i := range b.float32 {
+				if cap(b.float32[i]) != capacity {
+					return false
+				}
+			}
+			return true
+		},
+		resize: func(b buffer, size int) buffer {
+			for i := range b.float32 {
+				b.float32[i] = b.float32[i][:size]
+			}
+			return b
+		},
+		fill: func(b buffer, poison bool) {
+			var v float32
+			if poison {
+				v = float32(math.NaN())
+			}
+			for i := range b.float32 {
+				for j := range b.float32[i] {
+					b.float32[i][j] = v
+				}
+			}
+		},
+		poisoned: func(b buffer) bool {
+			for i := range b.float32 {
+				for _, v := range b.float32[i] {
+					if v == v {
+						return false
+					}
+				}
+			}
+			return true
+		},
+	},
+	intKind: {
+		make: func(numChannels, capacity int) buffer {
+			return buffer{int: sample.IntBuffer(numChannels, capacity)}
+		},
+		key: func(b buffer) interface{} {
+			if len(b.int) == 0 || cap(b.int[0]) == 0 {
+				return nil
+			}
+			return &b.int[0][:1][0]
+		},
+		fits: func(b buffer, numChannels, capacity int) bool {
+			if len(b.int) != numChannels {
+				return false
+			}
+			for i := range b.int {
+				if cap(b.int[i]) != capacity {
+					return false
+				}
+			}
+			return true
+		},
+		resize: func(b buffer, size int) buffer {
+			for i := range b.int {
+				b.int[i] = b.int[i][:size]
+			}
+			return b
+		},
+		fill: func(b buffer, poison bool) {
+			v := 0
+			if poison {
+				v = intPoison
+			}
+			for i := range b.int {
+				for j := range b.int[i] {
+					b.int[i][j] = v
+				}
+			}
+		},
+		poisoned: func(b buffer) bool {
+			for i := range b.int {
+				for _, v := range b.int[i] {
+					if v != intPoison {
+						return false
+					}
+				}
+			}
+			return true
+		},
+	},
+	interFloat64Kind: {
+		make: func(numChannels, capacity int) buffer {
+			return buffer{interFloat64: sample.InterFloat64Buffer(numChannels, capacity)}
+		},
+		key: func(b buffer) interface{} {
+			if cap(b.interFloat64.Data) == 0 {
+				return nil
+			}
+			return &b.interFloat64.Data[:1][0]
+		},
+		fits: func(b buffer, numChannels, capacity int) bool {
+			return b.interFloat64.NumChannels == numChannels && cap(b.interFloat64.Data) == numChannels*capacity
+		},
+		resize: func(b buffer, size int) buffer {
+			b.interFloat64.Data = b.interFloat64.Data[:b.interFloat64.NumChannels*size]
+			return b
+		},
+		fill: func(b buffer, poison bool) {
+			v := 0.0
+			if poison {
+				v = math.NaN()
+			}
+			for i := range b.interFloat64.Data {
+				b.interFloat64.Data[i] = v
+			}
+		},
+		poisoned: func(b buffer) bool {
+			for _, v := range b.interFloat64.Data {
+				if !math.IsNaN(v) {
+					return false
+				}
+			}
+			return true
+		},
+	},
+	interIntKind: {
+		make: func(numChannels, capacity int) buffer {
+			return buffer{interInt: sample.InterIntBuffer(numChannels, capacity, 0)}
+		},
+		key: func(b buffer) interface{} {
+			if cap(b.interInt.Data) == 0 {
+				return nil
+			}
+			return &b.interInt.Data[:1][0]
+		},
+		fits: func(b buffer, numChannels, capacity int) bool {
+			return b.interInt.NumChannels == numChannels && cap(b.interInt.Data) == numChannels*capacity
+		},
+		resize: func(b buffer, size int) buffer {
+			b.interInt.Data = b.interInt.Data[:b.interInt.NumChannels*size]
+			return b
+		},
+		fill: func(b buffer, poison bool) {
+			v := 0
+			if poison {
+				v = intPoison
+			}
+			for i := range b.interInt.Data {
+				b.interInt.Data[i] = v
+			}
+		},
+		poisoned: func(b buffer) bool {
+			for _, v := range b.interInt.Data {
+				if v != intPoison {
+					return false
+				}
+			}
+			return true
+		},
+	},
+}
@@ -4,41 +4,282 @@ import (
 	"sync"
 
 	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/metric"
+	"pipelined.dev/pipe/sample"
 )
 
-// Pool for signal buffers.
+// Pool for signal buffers. Buffers are kept in size classes: all pools
+// with the same number of channels and buffer size rounded up to the
+// power of two share the same buffers. Freed buffers are restored to
+// the full capacity, so trimmed buffers stay in circulation.
 type Pool struct {
 	bufferSize  int
 	numChannels int
-	pool        *sync.Pool
+	limit       *Limit
+	recorder    metric.PoolRecorder
+	tracker     *Tracker
+	owner       string
+	*class
+}
+
+// MaxFree is the number of free buffers of each format kept by size
+// class. Buffers released above it are dropped to the GC, so memory
+// used by pools doesn't stay at the highest demand.
+const MaxFree = 64
+
+// class holds free buffers of a single shape. Bounded free lists are
+// used instead of sync.Pool, so steady state doesn't allocate at all.
+type class struct {
+	capacity    int
+	numChannels int
+	sync.Mutex
+	buffers [numKinds][]buffer // free lists of each kind
+}
+
+// Limit bounds the number of outstanding buffers of pools that share
+// it. Allocations never block, but Wait blocks until a slot below the
+// limit is available and reserves it for the next allocation.
+// Allocations without reservation, like outputs of converting
+// processors, still take slots, so waiting callers are blocked until
+// those buffers are released too.
+type Limit struct {
+	sync.Mutex
+	max         int
+	outstanding int // allocated buffers
+	reserved    int // slots reserved by Wait, but not allocated yet
+	released    chan struct{}
+}
+
+// NewLimit returns a new limit of outstanding buffers.
+func NewLimit(max int) *Limit {
+	return &Limit{
+		max:      max,
+		released: make(chan struct{}, 1),
+	}
+}
+
+// WithLimit returns a copy of pool bounded by provided limit.
+func (p Pool) WithLimit(l *Limit) Pool {
+	p.limit = l
+	return p
+}
+
+// WithRecorder returns a copy of pool that counts its buffers in
+// provided recorder. Buffers are not counted if recorder is nil.
+func (p Pool) WithRecorder(r metric.PoolRecorder) Pool {
+	p.recorder = r
+	return p
+}
+
+// Wait blocks until the number of outstanding and reserved buffers is
+// below the limit and reserves a slot for the next allocation. It
+// returns false if cancel is closed before that.
+func (p Pool) Wait(cancel <-chan struct{}) bool {
+	l := p.limit
+	if l == nil {
+		return true
+	}
+	for {
+		l.Lock()
+		available := l.outstanding+l.reserved < l.max
+		if available {
+			l.reserved++
+			// pass the wake up to other waiting callers
+			if l.outstanding+l.reserved < l.max {
+				l.wake()
+			}
+		}
+		l.Unlock()
+		if available {
+			return true
+		}
+		select {
+		case <-l.released:
+		case <-cancel:
+			return false
+		}
+	}
+}
+
+// acquire captures the allocation of buffer. Reserved slot is taken if
+// there is one.
+func (p Pool) acquire(miss bool) {
+	if p.recorder != nil {
+		p.recorder.PoolAlloc(miss)
+	}
+	if l := p.limit; l != nil {
+		l.Lock()
+		if l.reserved > 0 {
+			l.reserved--
+		}
+		l.outstanding++
+		l.Unlock()
+	}
 }
 
-// New returns new pool.
-func New(numChannels, bufferSize int) Pool {
+// release captures the release of buffer and wakes up waiting caller.
+func (p Pool) release() {
+	if p.recorder != nil {
+		p.recorder.PoolFree()
+	}
+	if l := p.limit; l != nil {
+		l.Lock()
+		l.outstanding--
+		l.wake()
+		l.Unlock()
+	}
+}
+
+// wake wakes up one of waiting callers if there is any.
+func (l *Limit) wake() {
+	select {
+	case l.released <- struct{}{}:
+	default:
+	}
+}
+
+// shape identifies a size class.
+type shape struct {
+	numChannels int
+	capacity    int
+}
+
+// classes maps shapes to size classes of untracked pools.
+var classes sync.Map
+
+// Get returns a pool for buffers of provided shape. Pools of the same
+// size class share buffers.
+func Get(numChannels, bufferSize int) Pool {
 	return Pool{
 		bufferSize:  bufferSize,
 		numChannels: numChannels,
-		pool: &sync.Pool{
-			New: func() interface{} {
-				return signal.Float64Buffer(numChannels, bufferSize)
-			},
-		},
+		class: load(&classes, shape{
+			numChannels: numChannels,
+			capacity:    classCapacity(bufferSize),
+		}),
 	}
 }
 
+// load returns the size class of provided shape from classes map.
+func load(classes *sync.Map, s shape) *class {
+	c, _ := classes.LoadOrStore(s, &class{numChannels: s.numChannels, capacity: s.capacity})
+	return c.(*class)
+}
+
+// classCapacity returns the capacity of buffers in the class of
+// provided size.
+func classCapacity(bufferSize int) int {
+	capacity := 1
+	for capacity < bufferSize {
+		capacity <<= 1
+	}
+	return capacity
+}
+
 // Alloc retrieves new signal.Float64 buffer from the pool.
 func (p Pool) Alloc() signal.Float64 {
-	return p.pool.Get().(signal.Float64)
+	return p.alloc(float64Kind).float64
 }
 
-// Free returns signal.Float64 buffer to the pool. Buffer is also cleared up.
+// Free returns signal.Float64 buffer to the pool. Buffer is restored to
+// the full capacity and cleared up. Buffers of other classes are
+// dropped.
 func (p Pool) Free(b signal.Float64) {
-	if b.NumChannels() == p.numChannels && b.Size() == p.bufferSize {
-		for i := range b {
-			for j := range b[i] {
-				b[i][j] = 0
-			}
-		}
-		p.pool.Put(b)
+	p.free(float64Kind, buffer{float64: b})
+}
+
+// AllocFloat32 retrieves new sample.Float32 buffer from the pool.
+func (p Pool) AllocFloat32() sample.Float32 {
+	return p.alloc(float32Kind).float32
+}
+
+// FreeFloat32 returns sample.Float32 buffer to the pool. Buffer is
+// restored to the full capacity and cleared up.
+func (p Pool) FreeFloat32(b sample.Float32) {
+	p.free(float32Kind, buffer{float32: b})
+}
+
+// AllocInt retrieves new signal.Int buffer from the pool.
+func (p Pool) AllocInt() signal.Int {
+	return p.alloc(intKind).int
+}
+
+// FreeInt returns signal.Int buffer to the pool. Buffer is restored to
+// the full capacity and cleared up.
+func (p Pool) FreeInt(b signal.Int) {
+	p.free(intKind, buffer{int: b})
+}
+
+// AllocInterFloat64 retrieves new sample.InterFloat64 buffer from the
+// pool.
+func (p Pool) AllocInterFloat64() sample.InterFloat64 {
+	return p.alloc(interFloat64Kind).interFloat64
+}
+
+// FreeInterFloat64 returns sample.InterFloat64 buffer to the pool.
+// Buffer is restored to the full capacity and cleared up.
+func (p Pool) FreeInterFloat64(b sample.InterFloat64) {
+	p.free(interFloat64Kind, buffer{interFloat64: b})
+}
+
+// AllocInterInt retrieves new signal.InterInt buffer from the pool. Bit
+// depth of buffer is not set.
+func (p Pool) AllocInterInt() signal.InterInt {
+	return p.alloc(interIntKind).interInt
+}
+
+// FreeInterInt returns signal.InterInt buffer to the pool. Buffer is
+// restored to the full capacity and cleared up.
+func (p Pool) FreeInterInt(b signal.InterInt) {
+	b.BitDepth, b.Unsigned = 0, false
+	p.free(interIntKind, buffer{interInt: b})
+}
+
+// alloc retrieves a buffer of provided kind from the free list or
+// creates a new one.
+func (p Pool) alloc(k kind) buffer {
+	f := formats[k]
+	var b buffer
+	miss := true
+	p.Lock()
+	if n := len(p.buffers[k]); n > 0 {
+		b = p.buffers[k][n-1]
+		p.buffers[k] = p.buffers[k][:n-1]
+		miss = false
+	}
+	p.Unlock()
+	p.acquire(miss)
+	if miss {
+		b = f.make(p.numChannels, p.capacity)
+	}
+	if p.tracker != nil {
+		p.tracker.alloc(p.owner, f.key(b), miss || f.poisoned(b))
+		f.fill(b, false)
+	}
+	return f.resize(b, p.bufferSize)
+}
+
+// free returns a buffer of provided kind to the free list. Buffers of
+// other classes are dropped, tracked buffers are poisoned.
+func (p Pool) free(k kind, b buffer) {
+	f := formats[k]
+	key := f.key(b)
+	if key == nil {
+		return
+	}
+	if p.tracker != nil && !p.tracker.free(key) {
+		return
+	}
+	p.release()
+	if !f.fits(b, p.numChannels, p.capacity) {
+		return
+	}
+	b = f.resize(b, p.capacity)
+	f.fill(b, p.tracker != nil)
+	p.Lock()
+	if len(p.buffers[k]) < MaxFree {
+		p.buffers[k] = append(p.buffers[k], b)
 	}
+	p.Unlock()
 }
@@ -1,7 +1,10 @@
 package pool_test
 
 import (
+	"errors"
+	"math"
 	"testing"
+	"time"
 
 	"pipelined.dev/pipe/internal/pool"
 	"github.com/stretchr/testify/assert"
@@ -25,12 +28,243 @@ func TestPool(t *testing.T) {
 		},
 	}
 	for _, test := range tests {
-		p := pool.New(test.numChannels, test.bufferSize)
+		p := pool.Get(test.numChannels, test.bufferSize)
 		for i := 0; i < test.allocs; i++ {
 			b := p.Alloc()
 			assert.Equal(t, test.numChannels, b.NumChannels())
 			assert.Equal(t, test.bufferSize, b.Size())
 			p.Free(b)
+			inter := p.AllocInterFloat64()
+			assert.Equal(t, test.numChannels, inter.NumChannels)
+			assert.Equal(t, test.bufferSize, inter.Size())
+			p.FreeInterFloat64(inter)
+			ints := p.AllocInterInt()
+			assert.Equal(t, test.numChannels, ints.NumChannels)
+			assert.Equal(t, test.numChannels*test.bufferSize, len(ints.Data))
+			p.FreeInterInt(ints)
 		}
 	}
 }
+
+func TestSizeClasses(t *testing.T) {
+	numChannels := 2
+	p := pool.Get(numChannels, 1000)
+
+	// trimmed buffer is restored and reused
+	b := p.Alloc()
+	assert.Equal(t, 1000, b.Size())
+	b[0][0] = 1
+	for i := range b {
+		b[i] = b[i][:10]
+	}
+	p.Free(b)
+	reused := p.Alloc()
+	assert.Same(t, &b[0][0], &reused[0][0])
+	assert.Equal(t, 1000, reused.Size())
+	assert.Equal(t, 0.0, reused[0][0])
+
+	// pools of the same class share buffers
+	p.Free(reused)
+	shared := pool.Get(numChannels, 1024).Alloc()
+	assert.Same(t, &b[0][0], &shared[0][0])
+	assert.Equal(t, 1024, shared.Size())
+
+	// buffers of other classes are dropped
+	other := pool.Get(numChannels, 2048)
+	other.Free(shared)
+	assert.True(t, &b[0][0] != &other.Alloc()[0][0])
+
+	// steady state doesn't allocate
+	p.Free(shared)
+	allocs := testing.AllocsPerRun(100, func() {
+		p.Free(p.Alloc())
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestMaxFree(t *testing.T) {
+	// unique shape, so the class is not shared with other tests
+	p := pool.Get(3, 3)
+	released := make(map[*float64]bool)
+	buffers := make([][][]float64, 0, pool.MaxFree+1)
+	for i := 0; i < pool.MaxFree+1; i++ {
+		b := p.Alloc()
+		released[&b[0][0]] = true
+		buffers = append(buffers, b)
+	}
+	for _, b := range buffers {
+		p.Free(b)
+	}
+
+	// only MaxFree buffers are kept
+	var reused int
+	for i := 0; i < pool.MaxFree+1; i++ {
+		if b := p.Alloc(); released[&b[0][0]] {
+			reused++
+		}
+	}
+	assert.Equal(t, pool.MaxFree, reused)
+}
+
+// poolRecorder counts buffers of pool.
+type poolRecorder struct {
+	allocs, misses, frees int
+}
+
+func (r *poolRecorder) PoolAlloc(miss bool) {
+	r.allocs++
+	if miss {
+		r.misses++
+	}
+}
+
+func (r *poolRecorder) PoolFree() {
+	r.frees++
+}
+
+func TestRecorder(t *testing.T) {
+	var r poolRecorder
+	p := pool.Get(2, 512).WithRecorder(&r)
+	p.Free(p.Alloc())
+	p.FreeInt(p.AllocInt())
+	p.Free(p.Alloc())
+	assert.Equal(t, 3, r.allocs)
+	assert.Equal(t, 3, r.frees)
+	// the last buffer is reused
+	assert.True(t, r.misses < 3)
+
+	// pool without recorder doesn't count buffers
+	pool.Get(2, 512).Free(p.Alloc())
+	assert.Equal(t, 4, r.allocs)
+	assert.Equal(t, 3, r.frees)
+}
+
+func TestLimit(t *testing.T) {
+	limit := pool.NewLimit(2)
+	p := pool.Get(1, 512).WithLimit(limit)
+	cancel := make(chan struct{})
+
+	b1, b2 := p.Alloc(), p.Alloc()
+	released := make(chan bool)
+	go func() {
+		released <- p.Wait(cancel)
+	}()
+	select {
+	case <-released:
+		t.Fatal("wait must block when limit is reached")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.Free(b1)
+	assert.True(t, <-released)
+
+	// buffers of other formats are limited too
+	ints := p.AllocInt()
+	go func() {
+		released <- p.Wait(cancel)
+	}()
+	close(cancel)
+	assert.False(t, <-released)
+	p.Free(b2)
+	p.FreeInt(ints)
+	assert.True(t, p.Wait(nil))
+
+	// pool without limit never blocks
+	assert.True(t, pool.Get(1, 512).Wait(nil))
+}
+
+func TestLimitReserves(t *testing.T) {
+	limit := pool.NewLimit(2)
+	p1 := pool.Get(1, 512).WithLimit(limit)
+	p2 := pool.Get(2, 512).WithLimit(limit)
+	cancel := make(chan struct{})
+
+	// waits reserve slots before buffers are allocated
+	assert.True(t, p1.Wait(cancel))
+	assert.True(t, p2.Wait(cancel))
+	released := make(chan bool)
+	go func() {
+		released <- p1.Wait(cancel)
+	}()
+	select {
+	case <-released:
+		t.Fatal("wait must block when all slots are reserved")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// reserved allocations don't take extra slots
+	b1, b2 := p1.Alloc(), p2.Alloc()
+	p1.Free(b1)
+	assert.True(t, <-released)
+
+	// allocations without reservation take slots too
+	b1 = p1.Alloc()
+	converted := p2.AllocFloat32()
+	go func() {
+		released <- p1.Wait(cancel)
+	}()
+	p1.Free(b1)
+	select {
+	case <-released:
+		t.Fatal("wait must block until unreserved buffers are released")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p2.FreeFloat32(converted)
+	assert.True(t, <-released)
+	p2.Free(b2)
+}
+
+func TestTracker(t *testing.T) {
+	tracker := pool.NewTracker()
+	p := pool.Get(2, 512).WithTracker(tracker, "chain")
+
+	// outstanding buffers are leaked
+	b, ints := p.Alloc(), p.AllocInt()
+	assert.True(t, errors.Is(tracker.Leaks(), pool.ErrLeak))
+	p.Free(b)
+	p.FreeInt(ints)
+	assert.Nil(t, tracker.Leaks())
+	assert.Nil(t, tracker.Err())
+
+	// released buffers are poisoned
+	assert.True(t, math.IsNaN(b[0][0]))
+	// and cleared up when allocated again
+	b = p.Alloc()
+	assert.Equal(t, 0.0, b[1][511])
+	assert.Nil(t, tracker.Err())
+
+	// buffer is released twice
+	p.Free(b)
+	p.Free(b)
+	assert.True(t, errors.Is(tracker.Err(), pool.ErrDoubleFree))
+
+	// buffer is modified after release
+	tracker = pool.NewTracker()
+	p = p.WithTracker(tracker, "chain")
+	b = p.Alloc()
+	p.Free(b)
+	b[0][0] = 1
+	p.Free(p.Alloc())
+	assert.True(t, errors.Is(tracker.Err(), pool.ErrUseAfterFree))
+	assert.Nil(t, tracker.Leaks())
+}
+
+func TestTrackerClasses(t *testing.T) {
+	tracker1, tracker2 := pool.NewTracker(), pool.NewTracker()
+	p1 := pool.Get(2, 512).WithTracker(tracker1, "chain1")
+	p2 := pool.Get(2, 512).WithTracker(tracker2, "chain2")
+
+	// buffers are not shared between trackers
+	b := p1.Alloc()
+	p1.Free(b)
+	other := p2.Alloc()
+	assert.True(t, &b[0][0] != &other[0][0])
+	assert.True(t, &b[0][0] != &pool.Get(2, 512).Alloc()[0][0])
+	assert.Same(t, &b[0][0], &p1.Alloc()[0][0])
+
+	// leaks are reported by the tracker of allocating pool
+	assert.Nil(t, tracker1.Err())
+	assert.Nil(t, tracker2.Err())
+	assert.True(t, errors.Is(tracker1.Leaks(), pool.ErrLeak))
+	p2.Free(other)
+	assert.Nil(t, tracker2.Leaks())
+}
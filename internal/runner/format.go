@@ -0,0 +1,302 @@
+package runner
+
+import (
+	"math"
+
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/metric"
+	"pipelined.dev/pipe/sample"
+)
+
+// alloc allocates a new buffer of provided format for the message.
+func (m *Message) alloc(p Pool, f sample.Format) {
+	m.Format = f
+	switch {
+	case f.IsInterleaved() && f.IsInt():
+		m.InterInt = p.AllocInterInt()
+		m.InterInt.BitDepth = f.BitDepth()
+	case f.IsInterleaved():
+		m.InterFloat64 = p.AllocInterFloat64()
+	case f == sample.FormatFloat32:
+		m.Float32 = p.AllocFloat32()
+	case f.IsInt():
+		m.Int = p.AllocInt()
+	default:
+		m.Buffer = p.Alloc()
+	}
+}
+
+// free releases the buffer that carries the signal of message.
+func (m *Message) free(p Pool) {
+	switch f := m.Format; {
+	case f.IsInterleaved() && f.IsInt():
+		p.FreeInterInt(m.InterInt)
+	case f.IsInterleaved():
+		p.FreeInterFloat64(m.InterFloat64)
+	case f == sample.FormatFloat32:
+		p.FreeFloat32(m.Float32)
+	case f.IsInt():
+		p.FreeInt(m.Int)
+	default:
+		p.Free(m.Buffer)
+	}
+}
+
+// size returns the size of buffer that carries the signal of message.
+func (m *Message) size() int {
+	switch f := m.Format; {
+	case f.IsInterleaved() && f.IsInt():
+		return m.InterInt.Size()
+	case f.IsInterleaved():
+		return m.InterFloat64.Size()
+	case f == sample.FormatFloat32:
+		return m.Float32.Size()
+	case f.IsInt():
+		return sample.IntSize(m.Int)
+	default:
+		return m.Buffer.Size()
+	}
+}
+
+// trim sets the size of buffer that carries the signal of message.
+func (m *Message) trim(size int) {
+	switch f := m.Format; {
+	case f.IsInterleaved() && f.IsInt():
+		m.InterInt.Data = m.InterInt.Data[:size*m.InterInt.NumChannels]
+	case f.IsInterleaved():
+		m.InterFloat64.Data = m.InterFloat64.Data[:size*m.InterFloat64.NumChannels]
+	case f == sample.FormatFloat32:
+		for i := range m.Float32 {
+			m.Float32[i] = m.Float32[i][:size]
+		}
+	case f.IsInt():
+		for i := range m.Int {
+			m.Int[i] = m.Int[i][:size]
+		}
+	default:
+		for i := range m.Buffer {
+			m.Buffer[i] = m.Buffer[i][:size]
+		}
+	}
+}
+
+// formatted returns a copy of message with signal converted into
+// provided format, which must differ from the message format. New
+// buffer is allocated from the pool.
+func (m Message) formatted(p Pool, f sample.Format) Message {
+	// interleaved int buffers are converted with signal helpers, other
+	// interleaved buffers are converted through planar ones.
+	switch {
+	case m.Format.IsInterleaved() && m.Format.IsInt() && f == sample.FormatFloat64:
+		result := m.empty(p, f)
+		m.InterInt.CopyToFloat64(result.Buffer)
+		return result
+	case m.Format == sample.FormatFloat64 && f.IsInterleaved() && f.IsInt():
+		result := m.empty(p, f)
+		m.Buffer.CopyToInterInt(result.InterInt)
+		return result
+	case m.Format.IsInterleaved():
+		planar := m.deinterleaved(p)
+		if planar.Format == f {
+			return planar
+		}
+		result := planar.formatted(p, f)
+		planar.free(p)
+		return result
+	case f.IsInterleaved():
+		if m.Format == f.Planar() {
+			return m.interleaved(p)
+		}
+		planar := m.formatted(p, f.Planar())
+		result := planar.interleaved(p)
+		planar.free(p)
+		return result
+	}
+
+	result := m.empty(p, f)
+	switch {
+	case m.Format == sample.FormatFloat32 && f.IsInt():
+		sample.Float32ToInt(result.Int, m.Float32, f.BitDepth())
+	case m.Format == sample.FormatFloat32:
+		sample.Float32ToFloat64(result.Buffer, m.Float32)
+	case m.Format.IsInt() && f == sample.FormatFloat32:
+		sample.IntToFloat32(result.Float32, m.Int, m.Format.BitDepth())
+	case m.Format.IsInt() && f.IsInt():
+		sample.IntToInt(result.Int, m.Int, m.Format.BitDepth(), f.BitDepth())
+	case m.Format.IsInt():
+		sample.IntToFloat64(result.Buffer, m.Int, m.Format.BitDepth())
+	case f == sample.FormatFloat32:
+		sample.Float64ToFloat32(result.Float32, m.Buffer)
+	case f.IsInt():
+		sample.Float64ToInt(result.Int, m.Buffer, f.BitDepth())
+	}
+	return result
+}
+
+// copied returns a copy of message with signal copied into a new buffer
+// of provided format.
+func (m Message) copied(p Pool, f sample.Format) Message {
+	if m.Format != f {
+		return m.formatted(p, f)
+	}
+	result := m.empty(p, f)
+	switch {
+	case f.IsInterleaved() && f.IsInt():
+		copy(result.InterInt.Data, m.InterInt.Data)
+		result.InterInt.Unsigned = m.InterInt.Unsigned
+	case f.IsInterleaved():
+		copy(result.InterFloat64.Data, m.InterFloat64.Data)
+	case f == sample.FormatFloat32:
+		for i := range m.Float32 {
+			copy(result.Float32[i], m.Float32[i])
+		}
+	case f.IsInt():
+		for i := range m.Int {
+			copy(result.Int[i], m.Int[i])
+		}
+	default:
+		for i := range m.Buffer {
+			copy(result.Buffer[i], m.Buffer[i])
+		}
+	}
+	return result
+}
+
+// checksum returns FNV-1a hash of the signal carried by message.
+func (m *Message) checksum() uint64 {
+	const prime = 1099511628211
+	sum := uint64(14695981039346656037)
+	add := func(v uint64) {
+		for i := 0; i < 8; i++ {
+			sum ^= v & 0xff
+			sum *= prime
+			v >>= 8
+		}
+	}
+	switch f := m.Format; {
+	case f.IsInterleaved() && f.IsInt():
+		for _, v := range m.InterInt.Data {
+			add(uint64(v))
+		}
+	case f.IsInterleaved():
+		for _, v := range m.InterFloat64.Data {
+			add(math.Float64bits(v))
+		}
+	case f == sample.FormatFloat32:
+		for i := range m.Float32 {
+			for _, v := range m.Float32[i] {
+				add(uint64(math.Float32bits(v)))
+			}
+		}
+	case f.IsInt():
+		for i := range m.Int {
+			for _, v := range m.Int[i] {
+				add(uint64(v))
+			}
+		}
+	default:
+		for i := range m.Buffer {
+			for _, v := range m.Buffer[i] {
+				add(math.Float64bits(v))
+			}
+		}
+	}
+	return sum
+}
+
+// measure adds signal carried by message to the level meter. Int
+// samples are scaled to full scale of their bit depth, unsigned samples
+// are shifted back to zero first.
+func (m *Message) measure(l *metric.LevelMeter) {
+	switch f := m.Format; {
+	case f.IsInterleaved() && f.IsInt():
+		scale := fullScale(m.InterInt.BitDepth)
+		var shift float64
+		if m.InterInt.Unsigned {
+			shift = scale
+		}
+		for i, v := range m.InterInt.Data {
+			l.Add(i%m.InterInt.NumChannels, (float64(v)-shift)/scale)
+		}
+	case f.IsInterleaved():
+		for i, v := range m.InterFloat64.Data {
+			l.Add(i%m.InterFloat64.NumChannels, v)
+		}
+	case f == sample.FormatFloat32:
+		for i := range m.Float32 {
+			for _, v := range m.Float32[i] {
+				l.Add(i, float64(v))
+			}
+		}
+	case f.IsInt():
+		scale := fullScale(f.BitDepth())
+		for i := range m.Int {
+			for _, v := range m.Int[i] {
+				l.Add(i, float64(v)/scale)
+			}
+		}
+	default:
+		for i := range m.Buffer {
+			for _, v := range m.Buffer[i] {
+				l.Add(i, v)
+			}
+		}
+	}
+	l.Advance(m.size())
+}
+
+// fullScale returns the highest sample value of bit depth. It's the
+// same value that sample package uses for conversions.
+func fullScale(bitDepth signal.BitDepth) float64 {
+	if bitDepth == 0 {
+		return 1
+	}
+	return float64(int(1)<<(bitDepth-1) - 1)
+}
+
+// interleaved returns a copy of message with planar signal copied into
+// interleaved buffer of the same format.
+func (m Message) interleaved(p Pool) Message {
+	result := m.empty(p, m.Format|sample.Interleaved)
+	if m.Format.IsInt() {
+		sample.InterleaveInt(result.InterInt, m.Int)
+	} else {
+		sample.InterleaveFloat64(result.InterFloat64, m.Buffer)
+	}
+	return result
+}
+
+// deinterleaved returns a copy of message with interleaved signal copied
+// into planar buffer of the same format.
+func (m Message) deinterleaved(p Pool) Message {
+	result := m.empty(p, m.Format.Planar())
+	if m.Format.IsInt() {
+		sample.DeinterleaveInt(result.Int, m.InterInt)
+	} else {
+		sample.DeinterleaveFloat64(result.Buffer, m.InterFloat64)
+	}
+	return result
+}
+
+// empty returns a copy of message with a new buffer of provided format.
+// Buffer has the same size as the message buffer.
+func (m Message) empty(p Pool, f sample.Format) Message {
+	result := m
+	result.Buffer, result.Float32, result.Int = nil, nil, nil
+	result.InterFloat64, result.InterInt = sample.InterFloat64{}, signal.InterInt{}
+	result.alloc(p, f)
+	result.trim(m.size())
+	return result
+}
+
+// convert replaces the buffer of message with the buffer in provided
+// format. Old buffer is released into the pool.
+func (m *Message) convert(p Pool, f sample.Format) {
+	if m.Format == f {
+		return
+	}
+	result := m.formatted(p, f)
+	m.free(p)
+	*m = result
+}
@@ -0,0 +1,284 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+
+	"pipelined.dev/pipe/metric"
+)
+
+// Fused executes all components of a line in a single goroutine. For
+// each message pump, processors and sinks are called in order, so there
+// are no queues between them and Depth of components is ignored.
+type Fused struct {
+	Pump       Pump
+	Processors []Processor
+	Sinks      []Sink
+}
+
+// Run starts the fused runner. Pools contain the pool of pump followed
+// by the output pool of each processor.
+func (r Fused) Run(pools []Pool, pipeID string, cancel <-chan struct{}, give chan<- string, take <-chan Message) <-chan error {
+	step, errs := r.Start(pools, pipeID, cancel, request(pipeID, cancel, give, take))
+	go func() {
+		for step() {
+		}
+	}()
+	return errs
+}
+
+// Schedule submits the fused runner to the scheduler as a job of the
+// group. Group must be started for the run. Pools are the same as for
+// Run, errors are sent into the errors channel of the group.
+func (r Fused) Schedule(g *Group, pools []Pool, pipeID string, take <-chan Message) {
+	g.Lock()
+	cancel := g.cancel
+	g.Unlock()
+	run := r.start(pools, pipeID, cancel, receive(cancel, take))
+	run.group = g
+	g.s.submit(job{step: g.request(pipeID, run.step), priority: g.priority, group: g})
+}
+
+// Start prepares the run of fused line without starting goroutines.
+// Each call of returned step function receives a single message and
+// passes it through the line. Receive returns false if run is
+// cancelled. Step returns false when the run is done and errors channel
+// is closed.
+func (r Fused) Start(pools []Pool, pipeID string, cancel <-chan struct{}, receive func() (Message, bool)) (func() bool, <-chan error) {
+	run := r.start(pools, pipeID, cancel, receive)
+	return run.step, run.errs
+}
+
+// start returns a new run of fused line.
+func (r Fused) start(pools []Pool, pipeID string, cancel <-chan struct{}, receive func() (Message, bool)) *fusedRun {
+	return &fusedRun{
+		Fused:   r,
+		pools:   pools,
+		pipeID:  pipeID,
+		cancel:  cancel,
+		receive: receive,
+		errs:    make(chan error, 1),
+	}
+}
+
+// request returns a function that requests new message from the state
+// handle and receives it.
+func request(pipeID string, cancel <-chan struct{}, give chan<- string, take <-chan Message) func() (Message, bool) {
+	recv := receive(cancel, take)
+	return func() (Message, bool) {
+		// request new message
+		select {
+		case give <- pipeID:
+		case <-cancel:
+			return Message{}, false
+		}
+		return recv()
+	}
+}
+
+// receive returns a function that receives requested message.
+func receive(cancel <-chan struct{}, take <-chan Message) func() (Message, bool) {
+	return func() (Message, bool) {
+		select {
+		case m := <-take:
+			return m, true
+		case <-cancel:
+			return Message{}, false
+		}
+	}
+}
+
+// fusedRun is a single run of fused line. Line is executed in steps,
+// each step passes a single message through all components.
+type fusedRun struct {
+	Fused
+	pools           []Pool
+	pipeID          string
+	cancel          <-chan struct{}
+	receive         func() (Message, bool)
+	errs            chan error
+	group           *Group // errors are sent to the group if set
+	started         bool
+	pumpMeter       metric.MeasureFunc
+	processorMeters []metric.MeasureFunc
+	sinkMeters      []metric.MeasureFunc
+	pumpLevels      *metric.LevelMeter
+	processorLevels []*metric.LevelMeter
+	states          []processorState
+	message         Message   // kept in run to avoid allocation per step
+	messages        []Message // messages of sinks
+	refs            refList   // counters of sinks that share buffers
+	position        int64
+	limited         bool // pump reached the limit
+}
+
+// step executes the next step of the run. It returns false when the
+// run is done and errors channel is closed.
+func (r *fusedRun) step() bool {
+	if !r.started {
+		r.started = true
+		// Reset hooks
+		if err := r.reset(r.pipeID); err != nil {
+			r.done(err)
+			return false
+		}
+		r.init()
+	}
+	if ok, err := r.next(); !ok {
+		r.finish(err)
+		return false
+	}
+	return true
+}
+
+// init sets up meters and processors state of the run.
+func (r *fusedRun) init() {
+	r.pumpMeter = r.Pump.Meter(0)
+	r.pumpLevels = levelMeter(r.Pump.Levels, r.Pump.LevelInterval)
+	r.processorMeters = make([]metric.MeasureFunc, len(r.Processors))
+	r.processorLevels = make([]*metric.LevelMeter, len(r.Processors))
+	r.states = make([]processorState, len(r.Processors))
+	for i := range r.Processors {
+		r.processorMeters[i] = r.Processors[i].Meter(0)
+		r.processorLevels[i] = levelMeter(r.Processors[i].Levels, r.Processors[i].LevelInterval)
+		r.states[i] = r.Processors[i].newState()
+	}
+	r.sinkMeters = make([]metric.MeasureFunc, len(r.Sinks))
+	for i := range r.Sinks {
+		r.sinkMeters[i] = r.Sinks[i].Meter(0)
+	}
+	r.messages = make([]Message, len(r.Sinks))
+}
+
+// finish stops processors, calls flush hooks and closes errors
+// channel. Only the first error is sent.
+func (r *fusedRun) finish(err error) {
+	for i := range r.states {
+		r.states[i].close()
+	}
+	if flushErr := r.flush(r.pipeID); err == nil {
+		err = flushErr
+	}
+	r.done(err)
+}
+
+// done sends the error of the run and closes errors channel.
+func (r *fusedRun) done(err error) {
+	if r.group != nil {
+		r.group.done(err)
+		return
+	}
+	if err != nil {
+		r.errs <- err
+	}
+	close(r.errs)
+}
+
+// next passes the next message through the line. It returns false if
+// the run is done.
+func (r *fusedRun) next() (bool, error) {
+	var ok bool
+	if r.message, ok = r.receive(); !ok {
+		return false, r.interrupt(r.pipeID)
+	}
+	m := &r.message
+
+	// pump is done once it reached the limit
+	if r.limited {
+		return false, nil
+	}
+
+	// wait until pool has buffers available
+	if !r.pools[0].Wait(r.cancel) {
+		return false, r.interrupt(r.pipeID)
+	}
+
+	m.Params.ApplyTo(r.Pump.ID)
+	var err error
+	if r.limited, err = r.Pump.pump(r.pools[0], m, r.position, r.pumpMeter, r.pumpLevels); err != nil {
+		if err != io.EOF {
+			return false, fmt.Errorf("error running pump: %w", err)
+		}
+		return false, nil
+	}
+	r.position += int64(m.size())
+
+	for i, proc := range r.Processors {
+		m.Params.ApplyTo(proc.ID)
+		if err := proc.process(r.pools[i], r.pools[i+1], m, &r.states[i], r.processorMeters[i], r.processorLevels[i]); err != nil {
+			return false, fmt.Errorf("error running processor: %w", err)
+		}
+	}
+
+	sinkPool := r.pools[len(r.pools)-1]
+	prepare(sinkPool, &r.refs, r.pipeID, r.Sinks, *m, r.messages)
+	for i, sink := range r.Sinks {
+		r.messages[i].Params.ApplyTo(sink.ID)
+		if err := sink.sink(sinkPool, &r.messages[i], r.sinkMeters[i]); err != nil {
+			// release messages of remaining sinks
+			for _, m := range r.messages[i+1:] {
+				m.release(sinkPool)
+			}
+			return false, fmt.Errorf("error running sink: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// reset calls reset hooks of all components in order.
+func (r Fused) reset(pipeID string) error {
+	if err := call(r.Pump.Reset, pipeID); err != nil {
+		return fmt.Errorf("error resetting pump: %w", err)
+	}
+	for _, proc := range r.Processors {
+		if err := call(proc.Reset, pipeID); err != nil {
+			return fmt.Errorf("error resetting processor: %w", err)
+		}
+	}
+	for _, sink := range r.Sinks {
+		if err := call(sink.Reset, pipeID); err != nil {
+			return fmt.Errorf("error resetting sink: %w", err)
+		}
+	}
+	return nil
+}
+
+// flush calls flush hooks of all components. The first error is
+// returned.
+func (r Fused) flush(pipeID string) error {
+	var first error
+	if err := call(r.Pump.Flush, pipeID); err != nil {
+		first = fmt.Errorf("error flushing pump: %w", err)
+	}
+	for _, proc := range r.Processors {
+		if err := call(proc.Flush, pipeID); err != nil && first == nil {
+			first = fmt.Errorf("error flushing processor: %w", err)
+		}
+	}
+	for _, sink := range r.Sinks {
+		if err := call(sink.Flush, pipeID); err != nil && first == nil {
+			first = fmt.Errorf("error flushing sink: %w", err)
+		}
+	}
+	return first
+}
+
+// interrupt calls interrupt hooks of all components. The first error is
+// returned.
+func (r Fused) interrupt(pipeID string) error {
+	var first error
+	if err := call(r.Pump.Interrupt, pipeID); err != nil {
+		first = fmt.Errorf("error interrupting pump: %w", err)
+	}
+	for _, proc := range r.Processors {
+		if err := call(proc.Interrupt, pipeID); err != nil && first == nil {
+			first = fmt.Errorf("error interrupting processor: %w", err)
+		}
+	}
+	for _, sink := range r.Sinks {
+		if err := call(sink.Interrupt, pipeID); err != nil && first == nil {
+			first = fmt.Errorf("error interrupting sink: %w", err)
+		}
+	}
+	return first
+}
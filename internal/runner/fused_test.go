@@ -0,0 +1,130 @@
+package runner_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/internal/mock"
+	"pipelined.dev/pipe/internal/runner"
+	"pipelined.dev/pipe/metric"
+)
+
+func TestFused(t *testing.T) {
+	sampleRate := signal.SampleRate(44100)
+	numChannels, bufferSize := 1, 10
+	pump := &mock.Pump{
+		Limit:       10 * bufferSize,
+		NumChannels: numChannels,
+	}
+	processor := &mock.Processor{}
+	sink := &mock.Sink{}
+	pumpFn, _, _, _ := pump.MetaPump(pipeID)
+	processFn, _ := processor.MetaProcess(pipeID, sampleRate, numChannels)
+	sinkFn, _ := sink.MetaSink(pipeID, sampleRate, numChannels)
+	r := runner.Fused{
+		Pump: runner.Pump{
+			Fn:    pumpFn,
+			Meter: metric.Meter(metric.Default, pipeID, "pump", pump, sampleRate),
+			Hooks: pipe.BindHooks(pump),
+		},
+		Processors: []runner.Processor{
+			{
+				Fn:    processFn,
+				Meter: metric.Meter(metric.Default, pipeID, "processor", processor, sampleRate),
+				Hooks: pipe.BindHooks(processor),
+			},
+		},
+		Sinks: []runner.Sink{
+			{
+				Fn:    sinkFn,
+				Meter: metric.Meter(metric.Default, pipeID, "sink", sink, sampleRate),
+				Hooks: pipe.BindHooks(sink),
+			},
+		},
+	}
+	p := noOpPool{numChannels: numChannels, bufferSize: bufferSize}
+	pools := []runner.Pool{p, p}
+
+	// line is done when pump is done
+	cancel := make(chan struct{})
+	give := make(chan string)
+	take := make(chan runner.Message)
+	errs := r.Run(pools, pipeID, cancel, give, take)
+	go func() {
+		for id := range give {
+			take <- runner.Message{PipeID: id}
+		}
+	}()
+	_, ok := <-errs
+	assert.False(t, ok)
+	_, samples := sink.Count()
+	assert.Equal(t, pump.Limit, samples)
+	assert.True(t, pump.Flushed)
+	assert.True(t, processor.Flushed)
+	assert.True(t, sink.Flushed)
+	assert.False(t, sink.Interrupted)
+
+	// all components are interrupted on cancel
+	errs = r.Run(pools, pipeID, cancel, make(chan string), take)
+	close(cancel)
+	_, ok = <-errs
+	assert.False(t, ok)
+	assert.True(t, pump.Interrupted)
+	assert.True(t, processor.Interrupted)
+	assert.True(t, sink.Interrupted)
+	close(give)
+}
+
+func TestPumpLimit(t *testing.T) {
+	numChannels, bufferSize := 1, 10
+	tests := []struct {
+		name      string
+		signal    int
+		limit     int64
+		samples   int
+		messages  int
+		isLimited bool
+	}{
+		{"trimmed", 10 * bufferSize, 5*int64(bufferSize) + 5, 5*bufferSize + 5, 6, true},
+		{"end of buffer", 10 * bufferSize, 5 * int64(bufferSize), 5 * bufferSize, 5, true},
+		{"end of signal", 10 * bufferSize, 10 * int64(bufferSize), 10 * bufferSize, 10, false},
+	}
+	for _, c := range tests {
+		t.Run(c.name, func(t *testing.T) {
+			pump := &mock.Pump{Limit: c.signal, NumChannels: numChannels}
+			sink := &mock.Sink{}
+			pumpFn, _, _, _ := pump.MetaPump(pipeID)
+			sinkFn, _ := sink.MetaSink(pipeID, 44100, numChannels)
+			limited := false
+			r := runner.Fused{
+				Pump: runner.Pump{
+					Fn:      pumpFn,
+					Limit:   c.limit,
+					Limited: func() { limited = true },
+					Meter:   metric.Meter(metric.NewMemory(), pipeID, "pump", pump, 44100),
+				},
+				Sinks: []runner.Sink{
+					{
+						Fn:    sinkFn,
+						Meter: metric.Meter(metric.NewMemory(), pipeID, "sink", sink, 44100),
+					},
+				},
+			}
+			p := noOpPool{numChannels: numChannels, bufferSize: bufferSize}
+			step, errs := r.Start([]runner.Pool{p}, pipeID, make(chan struct{}), func() (runner.Message, bool) {
+				return runner.Message{PipeID: pipeID}, true
+			})
+			for step() {
+			}
+			assert.Nil(t, <-errs)
+			messages, samples := sink.Count()
+			assert.Equal(t, c.messages, messages)
+			assert.Equal(t, c.samples, samples)
+			assert.Equal(t, c.isLimited, limited)
+		})
+	}
+}
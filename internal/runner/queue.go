@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+// Queue transports messages between runners. It has a single producer
+// that calls Push and Close and a single consumer that calls Pop.
+type Queue interface {
+	// Push sends the message. It returns false if cancel is closed
+	// before message is sent.
+	Push(m Message, cancel <-chan struct{}) bool
+	// Pop receives the message. It returns false if queue is closed and
+	// drained or cancel is closed.
+	Pop(cancel <-chan struct{}) (Message, bool)
+	// Close is called when no more messages will be sent.
+	Close()
+}
+
+// newQueue returns a ring of provided depth if ring is true and
+// channel otherwise.
+func newQueue(ring bool, depth int) Queue {
+	if ring {
+		return NewRing(depth)
+	}
+	return make(Chan, depth)
+}
+
+// Chan is a queue based on channel.
+type Chan chan Message
+
+// Push implements Queue.
+func (c Chan) Push(m Message, cancel <-chan struct{}) bool {
+	select {
+	case c <- m:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// Pop implements Queue.
+func (c Chan) Pop(cancel <-chan struct{}) (Message, bool) {
+	select {
+	case m, ok := <-c:
+		return m, ok
+	case <-cancel:
+		return Message{}, false
+	}
+}
+
+// Close implements Queue.
+func (c Chan) Close() {
+	close(c)
+}
+
+// spins is the number of times ring yields the processor before it
+// blocks.
+const spins = 16
+
+// Ring is a preallocated lock-free single-producer single-consumer
+// queue. Push and Pop don't block while ring has space and messages.
+// Otherwise they yield the processor for a few times and then block
+// until the other side makes progress or cancel is closed.
+type Ring struct {
+	buffer   []Message
+	head     uint64 // position of the next pop, written by consumer
+	tail     uint64 // position of the next push, written by producer
+	closed   int32
+	notEmpty chan struct{}
+	notFull  chan struct{}
+}
+
+// NewRing returns a new ring that holds depth messages. Depth lower
+// than one is treated as one.
+func NewRing(depth int) *Ring {
+	if depth < 1 {
+		depth = 1
+	}
+	return &Ring{
+		buffer:   make([]Message, depth),
+		notEmpty: make(chan struct{}, 1),
+		notFull:  make(chan struct{}, 1),
+	}
+}
+
+// Push implements Queue.
+func (r *Ring) Push(m Message, cancel <-chan struct{}) bool {
+	size := uint64(len(r.buffer))
+	tail := r.tail
+	for i := 0; tail-atomic.LoadUint64(&r.head) == size; i++ {
+		if !wait(i, r.notFull, cancel) {
+			return false
+		}
+	}
+	r.buffer[tail%size] = m
+	atomic.StoreUint64(&r.tail, tail+1)
+	notify(r.notEmpty)
+	return true
+}
+
+// Pop implements Queue.
+func (r *Ring) Pop(cancel <-chan struct{}) (Message, bool) {
+	size := uint64(len(r.buffer))
+	head := r.head
+	for i := 0; head == atomic.LoadUint64(&r.tail); i++ {
+		// tail is checked again, messages pushed before close are kept
+		if atomic.LoadInt32(&r.closed) == 1 && head == atomic.LoadUint64(&r.tail) {
+			return Message{}, false
+		}
+		if !wait(i, r.notEmpty, cancel) {
+			return Message{}, false
+		}
+	}
+	m := r.buffer[head%size]
+	// release references to buffers
+	r.buffer[head%size] = Message{}
+	atomic.StoreUint64(&r.head, head+1)
+	notify(r.notFull)
+	return m, true
+}
+
+// Close implements Queue.
+func (r *Ring) Close() {
+	atomic.StoreInt32(&r.closed, 1)
+	notify(r.notEmpty)
+}
+
+// wait yields the processor for the first spins iterations and then
+// blocks until ready is signalled. It returns false if cancel is
+// closed.
+func wait(i int, ready, cancel <-chan struct{}) bool {
+	if i < spins {
+		select {
+		case <-cancel:
+			return false
+		default:
+		}
+		runtime.Gosched()
+		return true
+	}
+	select {
+	case <-ready:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// notify wakes up the blocked side of ring. Notification is kept if nobody
+// waits, so it's not lost if the other side is about to block.
+func notify(ready chan<- struct{}) {
+	select {
+	case ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,90 @@
+package runner_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe/internal/runner"
+)
+
+func TestRing(t *testing.T) {
+	t.Run("order", func(t *testing.T) {
+		r := runner.NewRing(2)
+		go func() {
+			for i := 0; i < 100; i++ {
+				r.Push(runner.Message{PipeID: string(rune('a' + i%26))}, nil)
+			}
+			r.Close()
+		}()
+		n := 0
+		for {
+			m, ok := r.Pop(nil)
+			if !ok {
+				break
+			}
+			assert.Equal(t, string(rune('a'+n%26)), m.PipeID)
+			n++
+		}
+		assert.Equal(t, 100, n)
+	})
+	t.Run("drain after close", func(t *testing.T) {
+		r := runner.NewRing(2)
+		assert.True(t, r.Push(runner.Message{PipeID: "a"}, nil))
+		assert.True(t, r.Push(runner.Message{PipeID: "b"}, nil))
+		r.Close()
+		m, ok := r.Pop(nil)
+		assert.True(t, ok)
+		assert.Equal(t, "a", m.PipeID)
+		m, ok = r.Pop(nil)
+		assert.True(t, ok)
+		assert.Equal(t, "b", m.PipeID)
+		_, ok = r.Pop(nil)
+		assert.False(t, ok)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		r := runner.NewRing(1)
+		cancel := make(chan struct{})
+		close(cancel)
+		_, ok := r.Pop(cancel)
+		assert.False(t, ok)
+		assert.True(t, r.Push(runner.Message{}, cancel))
+		assert.False(t, r.Push(runner.Message{}, cancel))
+	})
+}
+
+// This benchmark passes messages between two goroutines.
+func BenchmarkQueue(b *testing.B) {
+	queues := []struct {
+		name string
+		new  func(depth int) runner.Queue
+	}{
+		{
+			name: "channel",
+			new:  func(depth int) runner.Queue { return make(runner.Chan, depth) },
+		},
+		{
+			name: "ring",
+			new:  func(depth int) runner.Queue { return runner.NewRing(depth) },
+		},
+	}
+	for _, q := range queues {
+		for _, depth := range []int{1, 16} {
+			b.Run(fmt.Sprintf("%s/depth %d", q.name, depth), func(b *testing.B) {
+				queue := q.new(depth)
+				go func() {
+					for i := 0; i < b.N; i++ {
+						queue.Push(runner.Message{}, nil)
+					}
+					queue.Close()
+				}()
+				for {
+					if _, ok := queue.Pop(nil); !ok {
+						break
+					}
+				}
+			})
+		}
+	}
+}
@@ -1,61 +1,163 @@
 package runner
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"pipelined.dev/signal"
 
 	"pipelined.dev/pipe/internal/state"
+	"pipelined.dev/pipe/meta"
 	"pipelined.dev/pipe/metric"
+	"pipelined.dev/pipe/sample"
 )
 
-// Pool provides pooling of signal.Float64 buffers.
+// ErrMutation is returned in debug mode when sink changes the content
+// of shared buffer.
+var ErrMutation = errors.New("sink mutated shared buffer")
+
+// Pool provides pooling of signal buffers in all supported formats.
+// Wait blocks until pool can provide a new buffer and reserves it for
+// the next allocation, it returns false if cancel is closed before that.
 type Pool interface {
+	Wait(cancel <-chan struct{}) bool
 	Alloc() signal.Float64
 	Free(signal.Float64)
+	AllocFloat32() sample.Float32
+	FreeFloat32(sample.Float32)
+	AllocInt() signal.Int
+	FreeInt(signal.Int)
+	AllocInterFloat64() sample.InterFloat64
+	FreeInterFloat64(sample.InterFloat64)
+	AllocInterInt() signal.InterInt
+	FreeInterInt(signal.InterInt)
 }
 
-// Message is a main structure for pipe transport
+// Message is a main structure for pipe transport. Signal is carried by
+// the buffer that corresponds to the format of message.
 type Message struct {
-	SinkRefs int32          // number of sinks referencing buffer in this message.
-	PipeID   string         // ID of pipe which spawned this message.
-	Buffer   signal.Float64 // Buffer of message.
-	Params   state.Params   // params for pipe.
+	SinkRefs     *SinkRefs           // sinks sharing buffer in this message, nil if buffer is private.
+	PipeID       string              // ID of pipe which spawned this message.
+	Format       sample.Format       // format of buffer that carries signal.
+	Buffer       signal.Float64      // Buffer of message in float64 format.
+	Float32      sample.Float32      // Buffer of message in float32 format.
+	Int          signal.Int          // Buffer of message in int formats.
+	InterFloat64 sample.InterFloat64 // Buffer of message in interleaved float64 format.
+	InterInt     signal.InterInt     // Buffer of message in interleaved int formats.
+	Params       state.Params        // params for pipe.
+	Meta         meta.Data           // metadata of buffer.
+}
+
+// SinkRefs counts sinks that share the buffer of message. Sinks in debug
+// mode hold the lock while they are called, so shared buffer can only be
+// changed by the sink that checks it.
+type SinkRefs struct {
+	sync.Mutex
+	n    int32
+	list *refList // free list of the run
 }
 
 type (
 	// PumpFunc is closure of pipe.Pump that emits new messages.
-	PumpFunc func(signal.Float64) error
+	PumpFunc func(signal.Float64, *meta.Data) error
 
 	// ProcessFunc is closure of pipe.Processor that processes messages.
-	ProcessFunc func(signal.Float64) error
+	ProcessFunc func(signal.Float64, *meta.Data) error
+
+	// ConvertFunc is closure of pipe.Resampler and pipe.Remixer that
+	// processes messages into a new buffer.
+	ConvertFunc func(in, out signal.Float64) error
+
+	// ChannelFunc is closure of pipe.ChannelProcessor that processes a
+	// single channel of messages.
+	ChannelFunc func([]float64) error
 
 	// SinkFunc is closure of pipe.Sink that sinks messages.
-	SinkFunc func(signal.Float64) error
+	SinkFunc func(signal.Float64, *meta.Data) error
+
+	// FormatFunc is closure of components that work with buffers in
+	// format other than float64. Message buffer is converted into the
+	// format of component before the call.
+	FormatFunc func(*Message) error
 
-	// Pump executes pipe.Pump components.
+	// Pump executes pipe.Pump components. If Format is not float64,
+	// FormatFn is called instead of Fn. Depth is the capacity of output
+	// queue, zero depth makes channel unbuffered. If Ring is set, output
+	// queue is a lock-free ring instead of channel. If LevelInterval is
+	// set, levels of output are measured with Levels meter. If Limit is
+	// positive, pump is done once it pumped Limit samples, the last
+	// buffer is trimmed to the limit. Limited is called if pump had
+	// samples beyond the limit, so the signal was cut. Pump that reached
+	// the limit with full buffers is called once more to find out if
+	// the signal is over.
 	Pump struct {
-		ID    string
-		Fn    PumpFunc
-		Meter metric.ResetFunc
+		ID            string
+		Fn            PumpFunc
+		Format        sample.Format
+		FormatFn      FormatFunc
+		Depth         int
+		Ring          bool
+		Limit         int64
+		Limited       func()
+		Meter         metric.ResetFunc
+		Levels        metric.LevelResetFunc
+		LevelInterval time.Duration
 		Hooks
 	}
 
-	// Processor executes pipe.Processor components.
+	// Processor executes pipe.Processor components. If Convert is
+	// provided, it's called instead of Fn: output buffer is allocated
+	// from the out pool and input buffer is freed into the in pool.
+	// OutputSize returns the number of output samples for provided
+	// number of input samples, if it's nil, the size is not changed. It's
+	// applied to the totals of the run, so each output buffer gets the
+	// difference of totals and rounding doesn't accumulate. NumChannels is the
+	// number of output channels of converting processor. If Format is
+	// not float64, FormatFn is called instead of Fn. If SkipSilence is
+	// set, processor is not called for silent buffers after Tail number
+	// of silent samples passed through it. If Channels are provided,
+	// they're called instead of Fn for each channel of buffer by the
+	// goroutines of Workers. Depth, Ring and levels are defined the same
+	// as for pump.
 	Processor struct {
-		ID    string
-		Fn    ProcessFunc
-		Meter metric.ResetFunc
+		ID            string
+		Fn            ProcessFunc
+		Format        sample.Format
+		FormatFn      FormatFunc
+		Convert       ConvertFunc
+		Channels      []ChannelFunc
+		Workers       *Workers
+		OutputSize    func(int64) int64
+		NumChannels   int
+		SkipSilence   bool
+		Tail          int
+		Depth         int
+		Ring          bool
+		Meter         metric.ResetFunc
+		Levels        metric.LevelResetFunc
+		LevelInterval time.Duration
 		Hooks
 	}
 
-	// Sink executes pipe.Sink components.
+	// Sink executes pipe.Sink components. If Format is not float64,
+	// FormatFn is called instead of Fn. Mutable sinks receive a private
+	// buffer, it's copied only if other sinks share it. If Debug is set, shared buffers are checked for
+	// mutations after each call and sinks that share a buffer are called
+	// one at a time. Depth and Ring define the input queue.
 	Sink struct {
-		ID    string
-		Fn    SinkFunc
-		Meter metric.ResetFunc
+		ID       string
+		Fn       SinkFunc
+		Format   sample.Format
+		FormatFn FormatFunc
+		Mutable  bool
+		Debug    bool
+		Depth    int
+		Ring     bool
+		Meter    metric.ResetFunc
 		Hooks
 	}
 )
@@ -73,12 +175,13 @@ type (
 )
 
 // Run starts the Pump runner.
-func (r Pump) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, give chan<- string, take <-chan Message) (<-chan Message, <-chan error) {
-	out := make(chan Message, 1)
+func (r Pump) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, give chan<- string, take <-chan Message) (Queue, <-chan error) {
+	out := newQueue(r.Ring, r.Depth)
 	errs := make(chan error, 1)
-	meter := r.Meter()
+	meter := r.Meter(r.Depth)
+	levels := levelMeter(r.Levels, r.LevelInterval)
 	go func() {
-		defer close(out)
+		defer out.Close()
 		defer close(errs)
 		// Reset hook
 		if err := call(r.Reset, pipeID); err != nil {
@@ -91,9 +194,13 @@ func (r Pump) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, gi
 				errs <- fmt.Errorf("error flushing pump: %w", err)
 			}
 		}()
-		var err error
-		var m Message
-		for {
+		var (
+			err      error
+			m        Message
+			position int64
+			limited  bool
+		)
+		for !limited {
 			// request new message
 			select {
 			case give <- pipeID:
@@ -116,14 +223,16 @@ func (r Pump) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, gi
 
 			m.Params.ApplyTo(componentID) // apply params
 
-			// POOL: Allocate buffer here.
-			// allocate new buffer
-			m.Buffer = p.Alloc()
+			// wait until pool has buffers available
+			if !p.Wait(cancel) {
+				if err := call(r.Interrupt, pipeID); err != nil {
+					errs <- fmt.Errorf("error interrupting pump: %w", err)
+				}
+				return
+			}
 
-			err = r.Fn(m.Buffer)   // pump new buffer
-			meter(m.Buffer.Size()) // capture metrics
 			// handle error
-			if err != nil {
+			if limited, err = r.pump(p, &m, position, meter, levels); err != nil {
 				switch err {
 				case io.EOF:
 					// EOF is a good end.
@@ -133,10 +242,11 @@ func (r Pump) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, gi
 				return
 			}
 
+			position += int64(m.size())
+
 			// push message further
-			select {
-			case out <- m:
-			case <-cancel:
+			if !out.Push(m, cancel) {
+				m.free(p)
 				if err := call(r.Interrupt, pipeID); err != nil {
 					errs <- fmt.Errorf("error interrupting pump: %w", err)
 				}
@@ -147,13 +257,53 @@ func (r Pump) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, gi
 	return out, errs
 }
 
+// pump allocates the buffer of message and calls the pump. Buffer is
+// released if pump fails. Failed calls, including EOF, are not measured,
+// so meters count only pumped buffers. Levels meter is optional. The
+// buffer is trimmed to the limit of pump and true is returned if it's
+// the last one. EOF is returned if nothing is left after trimming.
+func (r Pump) pump(p Pool, m *Message, position int64, meter metric.MeasureFunc, levels *metric.LevelMeter) (bool, error) {
+	// POOL: Allocate buffer here.
+	m.alloc(p, r.Format)
+	m.Meta.Position = position
+	m.Meta.Timestamp = time.Now()
+	var err error
+	start := time.Now()
+	if r.FormatFn != nil {
+		err = r.FormatFn(m) // pump new formatted buffer
+	} else {
+		err = r.Fn(m.Buffer, &m.Meta) // pump new buffer
+	}
+	if err != nil {
+		m.free(p)
+		return false, err
+	}
+	limited := r.Limit > 0 && position+int64(m.size()) > r.Limit
+	if limited {
+		if r.Limited != nil {
+			r.Limited()
+		}
+		if position >= r.Limit {
+			m.free(p)
+			return true, io.EOF
+		}
+		m.trim(int(r.Limit - position))
+	}
+	meter(m.size(), time.Since(start)) // capture metrics
+	if levels != nil {
+		m.measure(levels)
+	}
+	return limited, nil
+}
+
 // Run starts the Processor runner.
-func (r Processor) Run(pipeID, componentID string, cancel <-chan struct{}, in <-chan Message) (<-chan Message, <-chan error) {
+func (r Processor) Run(inPool, outPool Pool, pipeID, componentID string, cancel <-chan struct{}, in Queue) (Queue, <-chan error) {
 	errs := make(chan error, 1)
-	out := make(chan Message, 1)
-	meter := r.Meter()
+	out := newQueue(r.Ring, r.Depth)
+	meter := r.Meter(r.Depth)
+	levels := levelMeter(r.Levels, r.LevelInterval)
 	go func() {
-		defer close(out)
+		defer out.Close()
 		defer close(errs)
 		// Reset hook
 		if err := call(r.Reset, pipeID); err != nil {
@@ -169,33 +319,28 @@ func (r Processor) Run(pipeID, componentID string, cancel <-chan struct{}, in <-
 		var err error
 		var m Message
 		var ok bool
+		state := r.newState()
+		defer state.close()
 		for {
 			// retrieve new message
-			select {
-			case m, ok = <-in:
-				if !ok {
-					return
-				}
-			case <-cancel:
-				if err := call(r.Interrupt, pipeID); err != nil {
-					errs <- fmt.Errorf("error interrupting processor: %w", err)
+			if m, ok = in.Pop(cancel); !ok {
+				if Cancelled(cancel) {
+					if err := call(r.Interrupt, pipeID); err != nil {
+						errs <- fmt.Errorf("error interrupting processor: %w", err)
+					}
 				}
 				return
 			}
 
 			m.Params.ApplyTo(componentID) // apply params
-			err = r.Fn(m.Buffer)          // process new buffer
-			if err != nil {
+			if err = r.process(inPool, outPool, &m, &state, meter, levels); err != nil {
 				errs <- fmt.Errorf("error running processor: %w", err)
 				return
 			}
 
-			meter(m.Buffer.Size()) // capture metrics
-
 			// send message further
-			select {
-			case out <- m:
-			case <-cancel:
+			if !out.Push(m, cancel) {
+				m.free(outPool)
 				if err := call(r.Interrupt, pipeID); err != nil {
 					errs <- fmt.Errorf("error interrupting pump: %w", err)
 				}
@@ -206,10 +351,122 @@ func (r Processor) Run(pipeID, componentID string, cancel <-chan struct{}, in <-
 	return out, errs
 }
 
+// processorState is the state of processor between messages.
+type processorState struct {
+	position int64    // position of converted buffers
+	input    int64    // number of samples received by converting processor
+	silence  int       // number of silent samples received in a row
+	channels *channels // channels of channel processors
+	workers  *Workers  // workers of channel processors
+}
+
+// newState returns the state for a new run of processor. Channel
+// processors are registered in their workers.
+func (r Processor) newState() processorState {
+	var s processorState
+	if r.Channels != nil {
+		if s.workers = r.Workers; s.workers == nil {
+			s.workers = NewWorkers(0)
+		}
+		s.channels = &channels{
+			fns:     r.Channels,
+			tasks:   s.workers.open(),
+			results: make(chan error, len(r.Channels)),
+		}
+	}
+	return s
+}
+
+// close unregisters channel processor from its workers.
+func (s processorState) close() {
+	if s.workers != nil {
+		s.workers.close()
+	}
+}
+
+// process calls the processor for the message. Message buffer is
+// released if processor fails. Levels meter is optional.
+func (r Processor) process(inPool, outPool Pool, m *Message, s *processorState, meter metric.MeasureFunc, levels *metric.LevelMeter) error {
+	skip := false
+	if r.SkipSilence {
+		skip, s.silence = r.skip(*m, s.silence)
+	}
+	var err error
+	start := time.Now()
+	if r.Convert != nil {
+		// converted buffer has its own position
+		m.convert(inPool, sample.FormatFloat64)
+		err = r.convert(inPool, outPool, m, s, skip)
+		m.Meta.Position = s.position
+		s.position += int64(m.Buffer.Size())
+	} else if !skip {
+		m.convert(inPool, r.Format)
+		// processors don't report silence of output
+		m.Meta.Silent = false
+		if r.FormatFn != nil {
+			err = r.FormatFn(m) // process new formatted buffer
+		} else if s.channels != nil {
+			err = s.channels.process(m.Buffer) // process channels of buffer
+		} else {
+			err = r.Fn(m.Buffer, &m.Meta) // process new buffer
+		}
+	}
+	if err != nil {
+		m.free(inPool)
+		return err
+	}
+	meter(m.size(), time.Since(start)) // capture metrics
+	if levels != nil {
+		m.measure(levels)
+	}
+	return nil
+}
+
+// skip checks if processor can be skipped for the message. It returns
+// the updated number of silent samples received in a row. Buffers within
+// the tail are processed, but they are not silent anymore.
+func (r Processor) skip(m Message, silence int) (bool, int) {
+	if !m.Meta.Silent {
+		return false, 0
+	}
+	if silence >= r.Tail {
+		return true, silence
+	}
+	return false, silence + m.size()
+}
+
+// convert allocates output buffer and calls Convert function. Input
+// buffer is released after successful call. If skip is true, Convert
+// is not called and output buffer stays silent.
+func (r Processor) convert(inPool, outPool Pool, m *Message, s *processorState, skip bool) error {
+	size := m.Buffer.Size()
+	if r.OutputSize != nil {
+		total := s.input + int64(size)
+		size = int(r.OutputSize(total) - r.OutputSize(s.input))
+		s.input = total
+	}
+	out := outPool.Alloc()
+	for i := range out {
+		out[i] = out[i][:size]
+	}
+	if !skip {
+		if err := r.Convert(m.Buffer, out); err != nil {
+			outPool.Free(out)
+			return err
+		}
+		// converter doesn't report silence of output
+		m.Meta.Silent = false
+	}
+	m.Meta.ScaleEvents(m.Buffer.Size(), out.Size())
+	inPool.Free(m.Buffer)
+	m.Buffer = out
+	return nil
+}
+
 // Run starts the sink runner.
-func (r Sink) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, in <-chan Message) <-chan error {
+func (r Sink) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, in Queue) <-chan error {
 	errs := make(chan error, 1)
-	meter := r.Meter()
+	meter := r.Meter(r.Depth)
 	go func() {
 		defer close(errs)
 		// Reset hook
@@ -227,42 +484,106 @@ func (r Sink) Run(p Pool, pipeID, componentID string, cancel <-chan struct{}, in
 		var ok bool
 		for {
 			// receive new message
-			select {
-			case m, ok = <-in:
-				if !ok {
-					return
-				}
-			case <-cancel:
-				if err := call(r.Interrupt, pipeID); err != nil {
-					errs <- fmt.Errorf("error interrupting sink: %w", err)
+			if m, ok = in.Pop(cancel); !ok {
+				if Cancelled(cancel) {
+					if err := call(r.Interrupt, pipeID); err != nil {
+						errs <- fmt.Errorf("error interrupting sink: %w", err)
+					}
 				}
 				return
 			}
 
 			m.Params.ApplyTo(componentID) // apply params
-			err := r.Fn(m.Buffer)         // sink a buffer
-			if err != nil {
+			if err := r.sink(p, &m, meter); err != nil {
 				errs <- fmt.Errorf("error running sink: %w", err)
 				return
 			}
-			meter(m.Buffer.Size()) // capture metrics
-			if atomic.AddInt32(&m.SinkRefs, -1) == 0 {
-				p.Free(m.Buffer)
-			}
 		}
 	}()
 
 	return errs
 }
 
+// sink calls the sink for the message. Buffer is released once all
+// sinks that share it are done.
+func (r Sink) sink(p Pool, m *Message, meter metric.MeasureFunc) error {
+	check := r.Debug && m.SinkRefs != nil
+	var sum uint64
+	if check {
+		m.SinkRefs.Lock()
+		sum = m.checksum()
+	}
+	var err error
+	start := time.Now()
+	if r.FormatFn != nil {
+		err = r.FormatFn(m) // sink a formatted buffer
+	} else {
+		err = r.Fn(m.Buffer, &m.Meta) // sink a buffer
+	}
+	if check {
+		if err == nil && sum != m.checksum() {
+			err = fmt.Errorf("sink %s: %w", r.ID, ErrMutation)
+		}
+		m.SinkRefs.Unlock()
+	}
+	if err == nil {
+		meter(m.size(), time.Since(start)) // capture metrics
+	}
+	m.release(p)
+	return err
+}
+
+// release frees the buffer of message once all sinks that share it are
+// done.
+func (m Message) release(p Pool) {
+	if m.SinkRefs == nil {
+		m.free(p)
+		return
+	}
+	if atomic.AddInt32(&m.SinkRefs.n, -1) == 0 {
+		m.free(p)
+		m.SinkRefs.list.put(m.SinkRefs)
+	}
+}
+
+// refList is a free list of sink reference counters, so broadcasting
+// doesn't allocate in steady state. Each run has its own list.
+type refList struct {
+	sync.Mutex
+	free []*SinkRefs
+}
+
+// get returns a counter set to n.
+func (l *refList) get(n int32) *SinkRefs {
+	l.Lock()
+	var c *SinkRefs
+	if last := len(l.free) - 1; last >= 0 {
+		c = l.free[last]
+		l.free = l.free[:last]
+	}
+	l.Unlock()
+	if c == nil {
+		c = &SinkRefs{list: l}
+	}
+	atomic.StoreInt32(&c.n, n)
+	return c
+}
+
+// put returns released counter to the list.
+func (l *refList) put(c *SinkRefs) {
+	l.Lock()
+	l.free = append(l.free, c)
+	l.Unlock()
+}
+
 // Broadcast passes messages to all sinks.
-func Broadcast(p Pool, pipeID string, sinks []Sink, cancel <-chan struct{}, in <-chan Message) []<-chan error {
+func Broadcast(p Pool, pipeID string, sinks []Sink, cancel <-chan struct{}, in Queue) []<-chan error {
 	//init errs for sinks error channels
 	errs := make([]<-chan error, 0, len(sinks))
-	//list of channels for broadcast
-	broadcasts := make([]chan Message, len(sinks))
+	//list of queues for broadcast
+	broadcasts := make([]Queue, len(sinks))
 	for i := range broadcasts {
-		broadcasts[i] = make(chan Message, 1)
+		broadcasts[i] = newQueue(sinks[i].Ring, sinks[i].Depth)
 	}
 
 	//start broadcast
@@ -274,20 +595,25 @@ func Broadcast(p Pool, pipeID string, sinks []Sink, cancel <-chan struct{}, in <
 		//close broadcasts on return
 		defer func() {
 			for i := range broadcasts {
-				close(broadcasts[i])
+				broadcasts[i].Close()
 			}
 		}()
-		for msg := range in {
+		// messages for sinks are prepared before any of them is sent,
+		// because sinks release shared buffer once they are done.
+		messages := make([]Message, len(sinks))
+		var refs refList
+		for {
+			msg, ok := in.Pop(nil)
+			if !ok {
+				return
+			}
+			prepare(p, &refs, pipeID, sinks, msg, messages)
 			for i := range broadcasts {
-				m := Message{
-					SinkRefs: int32(len(broadcasts)),
-					PipeID:   pipeID,
-					Buffer:   msg.Buffer,
-					Params:   msg.Params.Detach(sinks[i].ID),
-				}
-				select {
-				case broadcasts[i] <- m:
-				case <-cancel:
+				if !broadcasts[i].Push(messages[i], cancel) {
+					// release messages that weren't sent
+					for _, m := range messages[i:] {
+						m.release(p)
+					}
 					return
 				}
 			}
@@ -297,6 +623,79 @@ func Broadcast(p Pool, pipeID string, sinks []Sink, cancel <-chan struct{}, in <
 	return errs
 }
 
+// prepare fills messages for sinks. Sinks in other formats receive
+// their own copies, the rest share the buffer of msg. Mutable sinks
+// receive copies only if the buffer is shared: when no other sink
+// shares it, the last mutable sink gets the buffer of msg. Buffer of
+// msg is released if no sink receives it. Reference counters are taken
+// from refs of the run.
+func prepare(p Pool, refs *refList, pipeID string, sinks []Sink, msg Message, messages []Message) {
+	var shared int32
+	owner := -1 // mutable sink that receives the buffer of msg
+	for i := range sinks {
+		switch {
+		case sinks[i].shares(msg.Format):
+			shared++
+		case sinks[i].Format == msg.Format:
+			owner = i
+		}
+	}
+	var counter *SinkRefs
+	if shared > 0 {
+		counter = refs.get(shared)
+		owner = -1
+	}
+	for i := range sinks {
+		var m Message
+		switch {
+		case i == owner:
+			m = msg
+			m.SinkRefs = nil
+		case sinks[i].shares(msg.Format):
+			m = msg
+			m.SinkRefs = counter
+		default:
+			m = msg.copied(p, sinks[i].Format)
+			m.SinkRefs = nil
+		}
+		m.PipeID = pipeID
+		if len(sinks) > 1 {
+			m.Params = msg.Params.Detach(sinks[i].ID)
+		}
+		messages[i] = m
+	}
+	// counter cannot be used after messages are sent, sinks might
+	// release it
+	if shared == 0 && owner < 0 {
+		msg.free(p)
+	}
+}
+
+// shares returns true if sink can share the buffer of provided format
+// with other sinks.
+func (r Sink) shares(f sample.Format) bool {
+	return !r.Mutable && r.Format == f
+}
+
+// levelMeter returns a new level meter for the run. Nil is returned if
+// levels are not measured.
+func levelMeter(reset metric.LevelResetFunc, interval time.Duration) *metric.LevelMeter {
+	if reset == nil || interval <= 0 {
+		return nil
+	}
+	return reset(interval)
+}
+
+// Cancelled returns true if cancel is closed.
+func Cancelled(cancel <-chan struct{}) bool {
+	select {
+	case <-cancel:
+		return true
+	default:
+		return false
+	}
+}
+
 func call(h Hook, pipeID string) error {
 	if h != nil {
 		return h(pipeID)
@@ -3,6 +3,7 @@ package runner_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -12,6 +13,7 @@ import (
 	"pipelined.dev/pipe/internal/mock"
 	"pipelined.dev/pipe/internal/runner"
 	"pipelined.dev/pipe/metric"
+	"pipelined.dev/pipe/sample"
 )
 
 const (
@@ -24,12 +26,40 @@ type noOpPool struct {
 	bufferSize  int
 }
 
+func (p noOpPool) Wait(<-chan struct{}) bool {
+	return true
+}
+
 func (p noOpPool) Alloc() signal.Float64 {
 	return signal.Float64Buffer(p.numChannels, p.bufferSize)
 }
 
 func (p noOpPool) Free(signal.Float64) {}
 
+func (p noOpPool) AllocFloat32() sample.Float32 {
+	return sample.Float32Buffer(p.numChannels, p.bufferSize)
+}
+
+func (p noOpPool) FreeFloat32(sample.Float32) {}
+
+func (p noOpPool) AllocInt() signal.Int {
+	return sample.IntBuffer(p.numChannels, p.bufferSize)
+}
+
+func (p noOpPool) FreeInt(signal.Int) {}
+
+func (p noOpPool) AllocInterFloat64() sample.InterFloat64 {
+	return sample.InterFloat64Buffer(p.numChannels, p.bufferSize)
+}
+
+func (p noOpPool) FreeInterFloat64(sample.InterFloat64) {}
+
+func (p noOpPool) AllocInterInt() signal.InterInt {
+	return sample.InterIntBuffer(p.numChannels, p.bufferSize, 0)
+}
+
+func (p noOpPool) FreeInterInt(signal.InterInt) {}
+
 var testError = errors.New("test runner error")
 
 func TestPumpRunner(t *testing.T) {
@@ -87,10 +117,11 @@ func TestPumpRunner(t *testing.T) {
 	var ok bool
 
 	for _, c := range tests {
-		fn, sampleRate, _, _ := c.pump.Pump(pipeID)
+		fn, sampleRate, _, _ := c.pump.MetaPump(pipeID)
+		recorder := metric.NewMemory()
 		r := runner.Pump{
 			Fn:    fn,
-			Meter: metric.Meter(c.pump, signal.SampleRate(sampleRate)),
+			Meter: metric.Meter(recorder, pipeID, componentID, c.pump, signal.SampleRate(sampleRate)),
 			Hooks: pipe.BindHooks(c.pump),
 		}
 		cancel := make(chan struct{})
@@ -128,7 +159,7 @@ func TestPumpRunner(t *testing.T) {
 			take <- runner.Message{
 				PipeID: pipeID,
 			}
-			<-out
+			out.Pop(nil)
 			err := <-errs
 			assert.Equal(t, c.pump.ErrorOnCall, errors.Unwrap(err))
 		case c.pump.ErrorOnReset != nil:
@@ -141,14 +172,14 @@ func TestPumpRunner(t *testing.T) {
 				take <- runner.Message{
 					PipeID: pipeID,
 				}
-				<-out
+				out.Pop(nil)
 			}
 		}
 
 		pipe.Wait(errs)
 
 		// test channels closed
-		_, ok = <-out
+		_, ok = out.Pop(nil)
 		assert.False(t, ok)
 		_, ok = <-errs
 		assert.False(t, ok)
@@ -166,6 +197,13 @@ func TestPumpRunner(t *testing.T) {
 		} else {
 			assert.False(t, c.pump.Interrupted)
 		}
+
+		// failed calls, including EOF, are not measured
+		measured := 0
+		if c.pump.ErrorOnCall == nil && c.pump.ErrorOnReset == nil {
+			measured = c.pump.Limit / bufferSize
+		}
+		assert.Equal(t, measured, len(recorder.Measurements(componentID)))
 	}
 }
 
@@ -205,16 +243,16 @@ func TestProcessorRunner(t *testing.T) {
 	sampleRate := signal.SampleRate(44100)
 	numChannels := 1
 	for _, c := range tests {
-		fn, _ := c.processor.Process(pipeID, sampleRate, numChannels)
+		fn, _ := c.processor.MetaProcess(pipeID, sampleRate, numChannels)
 		r := runner.Processor{
 			Fn:    fn,
-			Meter: metric.Meter(c.processor, signal.SampleRate(sampleRate)),
+			Meter: metric.Meter(metric.Default, pipeID, componentID, c.processor, signal.SampleRate(sampleRate)),
 			Hooks: pipe.BindHooks(c.processor),
 		}
 
 		cancel := make(chan struct{})
-		in := make(chan runner.Message)
-		out, errs := r.Run(pipeID, componentID, cancel, in)
+		in := make(runner.Chan)
+		out, errs := r.Run(noOpPool{}, noOpPool{}, pipeID, componentID, cancel, in)
 		assert.NotNil(t, out)
 		assert.NotNil(t, errs)
 
@@ -240,7 +278,7 @@ func TestProcessorRunner(t *testing.T) {
 				in <- runner.Message{
 					PipeID: pipeID,
 				}
-				<-out
+				out.Pop(nil)
 			}
 			close(in)
 		}
@@ -263,6 +301,163 @@ func TestProcessorRunner(t *testing.T) {
 	}
 }
 
+func TestChannelProcessorRunner(t *testing.T) {
+	numChannels, messages := 4, 10
+	// processors share a single worker
+	workers := runner.NewWorkers(1)
+	var calls [2]int
+	processors := make([]runner.Processor, 2)
+	for i := range processors {
+		p := &mock.ChannelProcessor{Gain: 2}
+		fns := make([]runner.ChannelFunc, numChannels)
+		for c := range fns {
+			fn, _ := p.ProcessChannel(pipeID, 44100, c)
+			fns[c] = fn
+		}
+		i := i
+		processors[i] = runner.Processor{
+			Channels: fns,
+			Workers:  workers,
+			Meter: func(int) metric.MeasureFunc {
+				return func(int, time.Duration) {
+					calls[i]++
+				}
+			},
+		}
+	}
+
+	cancel := make(chan struct{})
+	in := make(runner.Chan)
+	out1, errs1 := processors[0].Run(noOpPool{}, noOpPool{}, pipeID, componentID, cancel, in)
+	out2, errs2 := processors[1].Run(noOpPool{}, noOpPool{}, pipeID, componentID, cancel, out1)
+	for i := 0; i < messages; i++ {
+		b := signal.Float64Buffer(numChannels, 8)
+		for c := range b {
+			for j := range b[c] {
+				b[c][j] = 0.25
+			}
+		}
+		in <- runner.Message{PipeID: pipeID, Buffer: b}
+		m, ok := out2.Pop(nil)
+		assert.True(t, ok)
+		for c := range m.Buffer {
+			assert.Equal(t, 1.0, m.Buffer[c][0])
+		}
+	}
+	close(in)
+	assert.Nil(t, pipe.Wait(errs1))
+	assert.Nil(t, pipe.Wait(errs2))
+	// processors are measured once per buffer
+	assert.Equal(t, [2]int{messages, messages}, calls)
+}
+
+func TestResamplerRunner(t *testing.T) {
+	tests := []struct {
+		sampleRate       signal.SampleRate
+		outputSampleRate signal.SampleRate
+		bufferSize       int
+		expectedSize     int
+		resampler        *mock.Resampler
+	}{
+		{
+			sampleRate:       22050,
+			outputSampleRate: 44100,
+			bufferSize:       512,
+			expectedSize:     1024,
+			resampler:        &mock.Resampler{},
+		},
+		{
+			sampleRate:       44100,
+			outputSampleRate: 22050,
+			bufferSize:       511,
+			expectedSize:     255,
+			resampler:        &mock.Resampler{},
+		},
+		{
+			sampleRate:       44100,
+			outputSampleRate: 22050,
+			bufferSize:       512,
+			resampler: &mock.Resampler{
+				Processor: mock.Processor{
+					ErrorOnCall: testError,
+				},
+			},
+		},
+	}
+	numChannels := 2
+	for _, c := range tests {
+		c.resampler.OutputSampleRate = c.outputSampleRate
+		fn, _, _ := c.resampler.Resample(pipeID, c.sampleRate, numChannels)
+		r := runner.Processor{
+			Convert: fn,
+			OutputSize: func(size int64) int64 {
+				return size * int64(c.outputSampleRate) / int64(c.sampleRate)
+			},
+			Meter: metric.Meter(metric.Default, pipeID, componentID, c.resampler, c.outputSampleRate),
+			Hooks: pipe.BindHooks(c.resampler),
+		}
+
+		cancel := make(chan struct{})
+		in := make(runner.Chan)
+		out, errs := r.Run(
+			noOpPool{numChannels: numChannels, bufferSize: c.bufferSize},
+			noOpPool{numChannels: numChannels, bufferSize: c.bufferSize * 2},
+			pipeID,
+			componentID,
+			cancel,
+			in,
+		)
+		in <- runner.Message{
+			PipeID: pipeID,
+			Buffer: signal.Float64Buffer(numChannels, c.bufferSize),
+		}
+		if c.resampler.ErrorOnCall != nil {
+			err := <-errs
+			assert.Equal(t, c.resampler.ErrorOnCall, errors.Unwrap(err))
+		} else {
+			m, _ := out.Pop(nil)
+			assert.Equal(t, numChannels, m.Buffer.NumChannels())
+			assert.Equal(t, c.expectedSize, m.Buffer.Size())
+			close(in)
+		}
+		pipe.Wait(errs)
+	}
+}
+
+func TestRemixerRunner(t *testing.T) {
+	remixer := &mock.Remixer{
+		OutputNumChannels: 3,
+	}
+	bufferSize := 512
+	fn, numChannels, _ := remixer.Remix(pipeID, 44100, 1)
+	r := runner.Processor{
+		Convert:     fn,
+		NumChannels: numChannels,
+		Meter:       metric.Meter(metric.Default, pipeID, componentID, remixer, 44100),
+		Hooks:       pipe.BindHooks(remixer),
+	}
+
+	cancel := make(chan struct{})
+	in := make(runner.Chan)
+	out, errs := r.Run(
+		noOpPool{numChannels: 1, bufferSize: bufferSize},
+		noOpPool{numChannels: numChannels, bufferSize: bufferSize},
+		pipeID,
+		componentID,
+		cancel,
+		in,
+	)
+	in <- runner.Message{
+		PipeID: pipeID,
+		Buffer: signal.Float64Buffer(1, bufferSize/2),
+	}
+	m, _ := out.Pop(nil)
+	assert.Equal(t, numChannels, m.Buffer.NumChannels())
+	assert.Equal(t, bufferSize/2, m.Buffer.Size())
+	close(in)
+	pipe.Wait(errs)
+}
+
 func TestSinkRunner(t *testing.T) {
 	tests := []struct {
 		messages        int
@@ -294,16 +489,16 @@ func TestSinkRunner(t *testing.T) {
 	sampleRate := signal.SampleRate(44100)
 	numChannels := 1
 	for _, c := range tests {
-		fn, _ := c.sink.Sink(pipeID, sampleRate, numChannels)
+		fn, _ := c.sink.MetaSink(pipeID, sampleRate, numChannels)
 
 		r := runner.Sink{
 			Fn:    fn,
-			Meter: metric.Meter(c.sink, signal.SampleRate(sampleRate)),
+			Meter: metric.Meter(metric.Default, pipeID, componentID, c.sink, signal.SampleRate(sampleRate)),
 			Hooks: pipe.BindHooks(c.sink),
 		}
 
 		cancel := make(chan struct{})
-		in := make(chan runner.Message)
+		in := make(runner.Chan)
 		errs := r.Run(noOpPool{}, pipeID, componentID, cancel, in)
 		assert.NotNil(t, errs)
 
@@ -322,8 +517,7 @@ func TestSinkRunner(t *testing.T) {
 		default:
 			for i := 0; i <= c.messages; i++ {
 				in <- runner.Message{
-					SinkRefs: 1,
-					PipeID:   pipeID,
+					PipeID: pipeID,
 				}
 			}
 			close(in)
@@ -349,19 +543,19 @@ func TestSinkRunner(t *testing.T) {
 
 func TestBroadcast(t *testing.T) {
 	tests := []struct {
-		sinks    []pipe.Sink
+		sinks    []*mock.Sink
 		messages int
 		nilHooks bool
 	}{
 		{
-			sinks: []pipe.Sink{
+			sinks: []*mock.Sink{
 				&mock.Sink{},
 				&mock.Sink{},
 			},
 			messages: 10,
 		},
 		{
-			sinks: []pipe.Sink{
+			sinks: []*mock.Sink{
 				&mock.Sink{},
 				&mock.Sink{},
 			},
@@ -376,10 +570,10 @@ func TestBroadcast(t *testing.T) {
 		// create runners
 		runners := make([]runner.Sink, len(test.sinks))
 		for i, sink := range test.sinks {
-			fn, _ := sink.Sink(pipeID, sampleRate, numChannels)
+			fn, _ := sink.MetaSink(pipeID, sampleRate, numChannels)
 			r := runner.Sink{
 				Fn:    fn,
-				Meter: metric.Meter(sink, sampleRate),
+				Meter: metric.Meter(metric.Default, pipeID, componentID, sink, sampleRate),
 			}
 			if !test.nilHooks {
 				r.Hooks = pipe.BindHooks(sink)
@@ -388,7 +582,7 @@ func TestBroadcast(t *testing.T) {
 		}
 
 		cancel := make(chan struct{})
-		in := make(chan runner.Message)
+		in := make(runner.Chan)
 		errorsList := runner.Broadcast(
 			noOpPool{},
 			pipeID,
@@ -411,3 +605,102 @@ func TestBroadcast(t *testing.T) {
 		}
 	}
 }
+
+func TestBroadcastMutation(t *testing.T) {
+	sampleRate := signal.SampleRate(44100)
+	sinks := []*mock.Sink{
+		&mock.Sink{Mutate: true},
+		&mock.Sink{},
+	}
+	ids := []string{"mutating", "clean"}
+	runners := make([]runner.Sink, len(sinks))
+	for i, sink := range sinks {
+		fn, _ := sink.MetaSink(pipeID, sampleRate, 1)
+		runners[i] = runner.Sink{
+			ID:    ids[i],
+			Fn:    fn,
+			Debug: true,
+			Meter: metric.Meter(metric.Default, pipeID, ids[i], sink, sampleRate),
+		}
+	}
+
+	in := make(runner.Chan)
+	errorsList := runner.Broadcast(noOpPool{}, pipeID, runners, make(chan struct{}), in)
+	in <- runner.Message{
+		PipeID: pipeID,
+		Buffer: signal.Float64{{1, 2, 3}},
+	}
+	close(in)
+
+	// only the sink that changed the buffer is blamed
+	err := <-errorsList[0]
+	assert.True(t, errors.Is(err, runner.ErrMutation))
+	assert.Contains(t, err.Error(), ids[0])
+	for err := range errorsList[1] {
+		assert.Nil(t, err)
+	}
+}
+
+// countingPool counts allocated float64 buffers.
+type countingPool struct {
+	noOpPool
+	allocs int
+}
+
+func (p *countingPool) Alloc() signal.Float64 {
+	p.allocs++
+	return p.noOpPool.Alloc()
+}
+
+func TestBroadcastCopies(t *testing.T) {
+	sampleRate := signal.SampleRate(44100)
+	tests := []struct {
+		sinks  []*mock.Sink
+		copies int
+	}{
+		{
+			// the only sink owns the buffer
+			sinks:  []*mock.Sink{{Mutable: true, Mutate: true}},
+			copies: 0,
+		},
+		{
+			// the last mutable sink owns the buffer
+			sinks:  []*mock.Sink{{Mutable: true, Mutate: true}, {Mutable: true, Mutate: true}},
+			copies: 1,
+		},
+		{
+			// buffer is shared
+			sinks:  []*mock.Sink{{Mutable: true, Mutate: true}, {}},
+			copies: 1,
+		},
+	}
+	for _, test := range tests {
+		runners := make([]runner.Sink, len(test.sinks))
+		for i, sink := range test.sinks {
+			fn, _ := sink.MetaSink(pipeID, sampleRate, 1)
+			runners[i] = runner.Sink{
+				Fn:      fn,
+				Mutable: sink.Mutable,
+				Meter:   metric.Meter(metric.NewMemory(), pipeID, componentID, sink, sampleRate),
+			}
+		}
+		p := &countingPool{noOpPool: noOpPool{numChannels: 1, bufferSize: 3}}
+		in := make(runner.Chan)
+		errorsList := runner.Broadcast(p, pipeID, runners, make(chan struct{}), in)
+		in <- runner.Message{
+			PipeID: pipeID,
+			Buffer: signal.Float64{{1, 2, 3}},
+		}
+		close(in)
+		for _, errs := range errorsList {
+			for err := range errs {
+				assert.Nil(t, err)
+			}
+		}
+		assert.Equal(t, test.copies, p.allocs)
+		// every sink receives the original signal
+		for _, sink := range test.sinks {
+			assert.Equal(t, signal.Float64{{1, 2, 3}}, sink.Buffer())
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package runner
+
+import (
+	"sync"
+)
+
+// Scheduler executes jobs with a fixed number of worker goroutines.
+// Job is a step function that returns false when the job is done. Jobs
+// are served in round-robin order and each job makes the number of
+// steps equal to its priority before the next job is served. Jobs of
+// paused group are parked until the group is resumed.
+type Scheduler struct {
+	sync.Mutex
+	ready   *sync.Cond
+	queue   []job
+	parked  int // number of parked jobs
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// job is a step function with its priority. Group is nil if job can't
+// be parked.
+type job struct {
+	step     func() bool
+	priority int
+	group    *Group
+}
+
+// NewScheduler starts a scheduler with provided number of workers. At
+// least one worker is started.
+func NewScheduler(workers int) *Scheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &Scheduler{}
+	s.ready = sync.NewCond(s)
+	s.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Submit adds a new job to the scheduler. Priority lower than one is
+// treated as one. Calling Submit after Close causes a panic.
+func (s *Scheduler) Submit(step func() bool, priority int) {
+	if priority < 1 {
+		priority = 1
+	}
+	s.submit(job{step: step, priority: priority})
+}
+
+// submit adds a new job to the scheduler.
+func (s *Scheduler) submit(j job) {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		panic("submit to closed scheduler")
+	}
+	s.queue = append(s.queue, j)
+	s.ready.Signal()
+}
+
+// Close waits until all submitted jobs are done and stops workers.
+// Parked jobs must be resumed for Close to return.
+func (s *Scheduler) Close() {
+	s.Lock()
+	s.closed = true
+	s.ready.Broadcast()
+	s.Unlock()
+	s.workers.Wait()
+}
+
+// work serves jobs until scheduler is closed and all jobs are done.
+func (s *Scheduler) work() {
+	defer s.workers.Done()
+	for {
+		s.Lock()
+		for len(s.queue) == 0 && (!s.closed || s.parked > 0) {
+			s.ready.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.Unlock()
+			return
+		}
+		j := s.queue[0]
+		s.queue = s.queue[1:]
+		s.Unlock()
+
+		if j.group != nil && j.group.park(j) {
+			continue
+		}
+		if j.run() {
+			s.Lock()
+			s.queue = append(s.queue, j)
+			s.ready.Signal()
+			s.Unlock()
+		}
+	}
+}
+
+// run makes the number of steps equal to the priority of job. It
+// returns false if job is done.
+func (j job) run() bool {
+	for i := 0; i < j.priority; i++ {
+		if !j.step() {
+			return false
+		}
+	}
+	return true
+}
+
+// Group is a group of fused lines of a pipe executed by the scheduler.
+// Jobs of paused group are parked, so they don't occupy workers. They
+// are submitted again when the group is resumed or its run is
+// cancelled. Errors of all lines of the run are sent into a single
+// channel, so group doesn't need goroutines.
+type Group struct {
+	sync.Mutex
+	s        *Scheduler
+	priority int
+	paused   bool
+	pausing  chan struct{} // closed when group is paused
+	resumed  chan struct{} // closed when paused group is resumed
+	parked   []job
+	cancel   <-chan struct{}
+	give     chan<- string
+	errs     chan error
+	lines    int // number of lines that are not done
+}
+
+// NewGroup returns a new group of jobs with provided priority. Priority
+// lower than one is treated as one.
+func NewGroup(s *Scheduler, priority int) *Group {
+	if priority < 1 {
+		priority = 1
+	}
+	return &Group{
+		s:        s,
+		priority: priority,
+		pausing:  make(chan struct{}),
+	}
+}
+
+// Start prepares the group for a new run of provided number of lines.
+// Returned channel receives the first error of lines and is closed when
+// all lines are done.
+func (g *Group) Start(cancel <-chan struct{}, give chan<- string, lines int) <-chan error {
+	g.Lock()
+	defer g.Unlock()
+	g.cancel, g.give = cancel, give
+	g.errs = make(chan error, 1)
+	g.lines = lines
+	if lines == 0 {
+		close(g.errs)
+	}
+	return g.errs
+}
+
+// Pause parks jobs of the group until Resume is called or the run is
+// cancelled.
+func (g *Group) Pause() {
+	g.Lock()
+	defer g.Unlock()
+	if g.paused {
+		return
+	}
+	g.paused = true
+	close(g.pausing)
+	g.resumed = make(chan struct{})
+	go g.watch(g.cancel, g.resumed)
+}
+
+// Resume submits parked jobs of the group again.
+func (g *Group) Resume() {
+	g.Lock()
+	defer g.Unlock()
+	if !g.paused {
+		return
+	}
+	g.paused = false
+	g.pausing = make(chan struct{})
+	close(g.resumed)
+	g.s.Lock()
+	g.s.queue = append(g.s.queue, g.parked...)
+	g.s.parked -= len(g.parked)
+	g.s.ready.Broadcast()
+	g.s.Unlock()
+	g.parked = nil
+}
+
+// watch resumes the group if its run is cancelled while paused.
+func (g *Group) watch(cancel, resumed <-chan struct{}) {
+	select {
+	case <-cancel:
+		g.Resume()
+	case <-resumed:
+	}
+}
+
+// park parks the job if group is paused. It returns false if job must
+// be executed.
+func (g *Group) park(j job) bool {
+	g.Lock()
+	defer g.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.parked = append(g.parked, j)
+	g.s.Lock()
+	g.s.parked++
+	g.s.Unlock()
+	return true
+}
+
+// request returns a step that requests a new message for the line
+// before it calls the step of fused run. It doesn't block if the group
+// is paused, the job is parked before the next step instead.
+func (g *Group) request(pipeID string, step func() bool) func() bool {
+	return func() bool {
+		g.Lock()
+		cancel, give, pausing := g.cancel, g.give, g.pausing
+		g.Unlock()
+		select {
+		case give <- pipeID:
+		case <-cancel:
+			// step receives cancellation
+		case <-pausing:
+			return true
+		}
+		return step()
+	}
+}
+
+// done captures that line is done. Only the first error is sent.
+func (g *Group) done(err error) {
+	g.Lock()
+	defer g.Unlock()
+	if err != nil {
+		select {
+		case g.errs <- err:
+		default:
+		}
+	}
+	g.lines--
+	if g.lines == 0 {
+		close(g.errs)
+	}
+}
@@ -0,0 +1,34 @@
+package runner_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe/internal/runner"
+)
+
+func TestScheduler(t *testing.T) {
+	s := runner.NewScheduler(1)
+	var order []byte
+	// job returns step function that records its steps
+	job := func(name byte, steps int, started <-chan struct{}) func() bool {
+		n := 0
+		return func() bool {
+			if n == 0 {
+				<-started
+			}
+			order = append(order, name)
+			n++
+			return n < steps
+		}
+	}
+	started := make(chan struct{})
+	s.Submit(job('a', 2, started), 1)
+	s.Submit(job('b', 6, started), 3)
+	close(started)
+	s.Close()
+
+	assert.Equal(t, "abbbabbb", string(order))
+	assert.Panics(t, func() { s.Submit(func() bool { return false }, 1) })
+}
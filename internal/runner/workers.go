@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"runtime"
+	"sync"
+
+	"pipelined.dev/signal"
+)
+
+// Workers process channels of buffers concurrently. The number of
+// goroutines is bounded and they are shared by all channel processors
+// that use the same Workers. Goroutines are started when the first
+// processor starts and stopped when the last one is done, so Workers
+// can be reused by the runs.
+type Workers struct {
+	n     int
+	mu    sync.Mutex
+	users int
+	tasks chan task
+}
+
+// task is a single channel of buffer.
+type task struct {
+	fn      ChannelFunc
+	data    []float64
+	results chan<- error
+}
+
+// NewWorkers returns workers with at most n goroutines. If n is not
+// positive, GOMAXPROCS goroutines are used.
+func NewWorkers(n int) *Workers {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	return &Workers{n: n}
+}
+
+// open registers a processor and starts goroutines if they are not
+// running. It returns the queue of tasks.
+func (w *Workers) open() chan<- task {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.users == 0 {
+		w.tasks = make(chan task, w.n)
+		for i := 0; i < w.n; i++ {
+			go run(w.tasks)
+		}
+	}
+	w.users++
+	return w.tasks
+}
+
+// close unregisters a processor and stops goroutines once there are
+// no processors left.
+func (w *Workers) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.users--
+	if w.users == 0 {
+		close(w.tasks)
+		w.tasks = nil
+	}
+}
+
+func run(tasks <-chan task) {
+	for t := range tasks {
+		t.results <- t.fn(t.data)
+	}
+}
+
+// channels are channel functions of a single processor bound to
+// workers. Results have capacity for all channels, so workers never
+// block on them.
+type channels struct {
+	fns     []ChannelFunc
+	tasks   chan<- task
+	results chan error
+}
+
+// process distributes channels of buffer between workers and waits
+// until all of them are done. The first error is returned.
+func (c channels) process(b signal.Float64) error {
+	for i := range b {
+		c.tasks <- task{fn: c.fns[i], data: b[i], results: c.results}
+	}
+	var first error
+	for range b {
+		if err := <-c.results; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
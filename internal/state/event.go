@@ -26,7 +26,7 @@ type (
 	// run event is sent to start the run.
 	run struct {
 		context.Context
-		BufferSize int
+		start StartFunc
 		errors
 	}
 
@@ -55,14 +55,15 @@ func (f errors) feedback() chan error {
 	return f
 }
 
-// Run sends a run event into handle.
+// Run sends a run event into handle. Start function is called to start
+// the components of this run.
 // Calling this method after Interrupt, will cause panic.
-func (h *Handle) Run(ctx context.Context, bufferSize int) chan error {
+func (h *Handle) Run(ctx context.Context, start StartFunc) chan error {
 	errors := make(chan error, 1)
 	h.events <- run{
-		Context:    ctx,
-		BufferSize: bufferSize,
-		errors:     errors,
+		Context: ctx,
+		start:   start,
+		errors:  errors,
 	}
 	return errors
 }
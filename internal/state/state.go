@@ -29,25 +29,30 @@ type (
 		// cancel the line execution.
 		// created in run event, closed on cancel event or when error is recieved.
 		cancelFn     context.CancelFunc
-		startFn      StartFunc
 		newMessageFn NewMessageFunc
 		pushParamsFn PushParamsFunc
+		pauseFn      PauseFunc
 	}
 
 	// merger fans-in error channels.
 	merger struct {
-		wg     *sync.WaitGroup
-		errors chan error
+		errors <-chan error
 	}
 
-	// StartFunc is the closure to trigger the start of a pipe.
-	StartFunc func(bufferSize int, cancel <-chan struct{}, messages chan<- string) []<-chan error
+	// StartFunc is the closure to trigger the start of a pipe. It's
+	// provided with every run event.
+	StartFunc func(cancel <-chan struct{}, messages chan<- string) []<-chan error
 
 	// NewMessageFunc is the closure to send a message into a pipe.
 	NewMessageFunc func(pipeID string)
 
 	// PushParamsFunc is the closure to push new params into pipe.
 	PushParamsFunc func(params Params)
+
+	// PauseFunc is the closure to notify pipe that its run is paused or
+	// not paused anymore. It's called when paused state is entered and
+	// left.
+	PauseFunc func(paused bool)
 )
 
 type (
@@ -74,12 +79,13 @@ const (
 	closed
 )
 
-// NewHandle returns new initalized handle that can be used to manage lifecycle.
-func NewHandle(start StartFunc, newMessage NewMessageFunc, pushParams PushParamsFunc) *Handle {
+// NewHandle returns new initalized handle that can be used to manage
+// lifecycle. Pause function is optional.
+func NewHandle(newMessage NewMessageFunc, pushParams PushParamsFunc, pause PauseFunc) *Handle {
 	h := Handle{
-		startFn:      start,
 		newMessageFn: newMessage,
 		pushParamsFn: pushParams,
+		pauseFn:      pause,
 		events:       make(chan event, 1),
 		params:       make(chan Params, 1),
 	}
@@ -173,7 +179,7 @@ func (h *Handle) transition(s state, e event) (state, error) {
 			h.messages = make(chan string)
 			ctx, cancelFn := context.WithCancel(ev.Context)
 			h.cancelFn = cancelFn
-			h.merger = mergeErrors(h.startFn(ev.BufferSize, ctx.Done(), h.messages))
+			h.merger = mergeErrors(ev.start(ctx.Done(), h.messages))
 			return h.running(), nil
 		}
 	case running:
@@ -181,6 +187,7 @@ func (h *Handle) transition(s state, e event) (state, error) {
 		case interrupt:
 			return h.interrupting(), nil
 		case pause:
+			h.pause(true)
 			return h.paused(), nil
 		case done:
 			return h.ready(), nil
@@ -188,10 +195,14 @@ func (h *Handle) transition(s state, e event) (state, error) {
 	case paused:
 		switch e.(type) {
 		case interrupt:
-			return h.interrupting(), nil
+			s := h.interrupting()
+			h.pause(false)
+			return s, nil
 		case resume:
+			h.pause(false)
 			return h.running(), nil
 		case done:
+			h.pause(false)
 			return h.ready(), nil
 		}
 	case interrupting:
@@ -203,6 +214,13 @@ func (h *Handle) transition(s state, e event) (state, error) {
 	return s, ErrInvalidState
 }
 
+// pause calls pause function if it's provided.
+func (h *Handle) pause(paused bool) {
+	if h.pauseFn != nil {
+		h.pauseFn(paused)
+	}
+}
+
 // ready states that the handle is ready and user
 // can start it, send params or interrupt it.
 func (h *Handle) ready() state {
@@ -267,34 +285,36 @@ func (s stateType) String() string {
 	}
 }
 
-// merge error channels from all components into one.
+// merge error channels from all components into one. Single channel is
+// used as is.
 func mergeErrors(errcList []<-chan error) merger {
-	m := merger{
-		wg:     &sync.WaitGroup{},
-		errors: make(chan error, 1),
+	if len(errcList) == 1 {
+		return merger{errors: errcList[0]}
 	}
+	var wg sync.WaitGroup
+	out := make(chan error, 1)
 
 	//function to wait for error channel
-	m.wg.Add(len(errcList))
+	wg.Add(len(errcList))
 	for _, ec := range errcList {
-		go m.done(ec)
+		go wait(&wg, ec, out)
 	}
 
 	//wait and close out
 	go func() {
-		m.wg.Wait()
-		close(m.errors)
+		wg.Wait()
+		close(out)
 	}()
-	return m
+	return merger{errors: out}
 }
 
-// done blocks until error is received or channel is closed.
-func (m merger) done(ec <-chan error) {
+// wait blocks until error is received or channel is closed.
+func wait(wg *sync.WaitGroup, ec <-chan error, out chan<- error) {
 	if err, ok := <-ec; ok {
 		select {
-		case m.errors <- err:
+		case out <- err:
 		default:
 		}
 	}
-	m.wg.Done()
+	wg.Done()
 }
@@ -18,7 +18,7 @@ type startFuncMock struct{}
 
 // send channel is closed ONLY when any messages were sent
 func (m *startFuncMock) fn(send chan struct{}, errorOnSend, errorOnClose error) state.StartFunc {
-	return func(bufferSize int, cancel <-chan struct{}, give chan<- string) []<-chan error {
+	return func(cancel <-chan struct{}, give chan<- string) []<-chan error {
 		errs := make(chan error)
 		go func() {
 			defer close(errs)
@@ -161,12 +161,15 @@ func TestStates(t *testing.T) {
 		pushParamsMock := &pushParamsFuncMock{}
 		p := &paramMock{uid: "params"}
 		send := make(chan struct{})
-		h := state.NewHandle(
-			startMock.fn(send, c.errorOnSend, c.errorOnClose),
-			newMessageMock.fn(),
-			pushParamsMock.fn(),
-		)
-		go state.Loop(h)
+		h := handle{
+			Handle: state.NewHandle(
+				newMessageMock.fn(),
+				pushParamsMock.fn(),
+				nil,
+			),
+			start: startMock.fn(send, c.errorOnSend, c.errorOnClose),
+		}
+		go state.Loop(h.Handle)
 
 		// reach tested state
 		// remember last errs channel
@@ -207,22 +210,28 @@ func TestStates(t *testing.T) {
 	goleak.VerifyNoLeaks(t)
 }
 
-type transition func(*state.Handle) chan error
+// handle binds start function to the handle under test.
+type handle struct {
+	*state.Handle
+	start state.StartFunc
+}
+
+type transition func(handle) chan error
 
 var (
-	run = func(h *state.Handle) chan error {
-		return h.Run(context.Background(), 0)
+	run = func(h handle) chan error {
+		return h.Run(context.Background(), h.start)
 	}
-	resume = func(h *state.Handle) chan error {
+	resume = func(h handle) chan error {
 		return h.Resume()
 	}
-	pause = func(h *state.Handle) chan error {
+	pause = func(h handle) chan error {
 		return h.Pause()
 	}
 )
 
 func runWithContext(ctx context.Context) transition {
-	return func(h *state.Handle) chan error {
-		return h.Run(ctx, 0)
+	return func(h handle) chan error {
+		return h.Run(ctx, h.start)
 	}
 }
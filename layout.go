@@ -0,0 +1,159 @@
+package pipe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChannelPosition identifies the speaker position or the component of
+// the signal that channel carries.
+type ChannelPosition uint8
+
+// Speaker positions.
+const (
+	// PositionUnknown is used when channel position is not defined.
+	PositionUnknown ChannelPosition = iota
+	FrontLeft
+	FrontRight
+	FrontCenter
+	LowFrequency
+	BackLeft
+	BackRight
+	FrontLeftOfCenter
+	FrontRightOfCenter
+	BackCenter
+	SideLeft
+	SideRight
+	// AmbisonicW is omnidirectional component of ambisonics signal.
+	AmbisonicW
+	// AmbisonicX is front-back component of ambisonics signal.
+	AmbisonicX
+	// AmbisonicY is left-right component of ambisonics signal.
+	AmbisonicY
+	// AmbisonicZ is up-down component of ambisonics signal.
+	AmbisonicZ
+)
+
+var positionNames = map[ChannelPosition]string{
+	PositionUnknown:    "Unknown",
+	FrontLeft:          "FL",
+	FrontRight:         "FR",
+	FrontCenter:        "FC",
+	LowFrequency:       "LFE",
+	BackLeft:           "BL",
+	BackRight:          "BR",
+	FrontLeftOfCenter:  "FLC",
+	FrontRightOfCenter: "FRC",
+	BackCenter:         "BC",
+	SideLeft:           "SL",
+	SideRight:          "SR",
+	AmbisonicW:         "W",
+	AmbisonicX:         "X",
+	AmbisonicY:         "Y",
+	AmbisonicZ:         "Z",
+}
+
+func (p ChannelPosition) String() string {
+	if name, ok := positionNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChannelPosition(%d)", p)
+}
+
+// ChannelLayout describes the position of each channel in the signal.
+// Number of channels is equal to the length of layout.
+type ChannelLayout []ChannelPosition
+
+// Common channel layouts.
+var (
+	LayoutMono      = ChannelLayout{FrontCenter}
+	LayoutStereo    = ChannelLayout{FrontLeft, FrontRight}
+	LayoutQuad      = ChannelLayout{FrontLeft, FrontRight, BackLeft, BackRight}
+	Layout51        = ChannelLayout{FrontLeft, FrontRight, FrontCenter, LowFrequency, BackLeft, BackRight}
+	Layout71        = ChannelLayout{FrontLeft, FrontRight, FrontCenter, LowFrequency, BackLeft, BackRight, SideLeft, SideRight}
+	LayoutAmbisonic = ChannelLayout{AmbisonicW, AmbisonicY, AmbisonicZ, AmbisonicX} // first-order ambisonics, ACN order.
+)
+
+// DefaultLayout returns the common layout for provided number of
+// channels. If there is no common layout, all positions are unknown.
+// Returned layout is a copy and can be changed by caller.
+func DefaultLayout(numChannels int) ChannelLayout {
+	var layout ChannelLayout
+	switch numChannels {
+	case 1:
+		layout = LayoutMono
+	case 2:
+		layout = LayoutStereo
+	case 4:
+		layout = LayoutQuad
+	case 6:
+		layout = Layout51
+	case 8:
+		layout = Layout71
+	default:
+		return make(ChannelLayout, numChannels)
+	}
+	return append(ChannelLayout(nil), layout...)
+}
+
+// NumChannels returns the number of channels in the layout.
+func (l ChannelLayout) NumChannels() int {
+	return len(l)
+}
+
+// Index returns the index of the first channel with provided position.
+// If layout doesn't contain position, -1 is returned.
+func (l ChannelLayout) Index(p ChannelPosition) int {
+	for i := range l {
+		if l[i] == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// Equal checks if layouts have the same positions in the same order.
+func (l ChannelLayout) Equal(other ChannelLayout) bool {
+	if len(l) != len(other) {
+		return false
+	}
+	for i := range l {
+		if l[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (l ChannelLayout) String() string {
+	positions := make([]string, 0, len(l))
+	for _, p := range l {
+		positions = append(positions, p.String())
+	}
+	return fmt.Sprintf("[%s]", strings.Join(positions, " "))
+}
+
+// outputLayout returns the layout reported by component. If component
+// doesn't report the layout, default one is used.
+func outputLayout(pipeID string, component interface{}, numChannels int) (ChannelLayout, error) {
+	v, ok := component.(LayoutReporter)
+	if !ok {
+		return DefaultLayout(numChannels), nil
+	}
+	layout := v.Layout(pipeID)
+	if layout == nil {
+		return DefaultLayout(numChannels), nil
+	}
+	if layout.NumChannels() != numChannels {
+		return nil, fmt.Errorf("layout %v doesn't match %d channels", layout, numChannels)
+	}
+	return layout, nil
+}
+
+// receiveLayout passes the input layout to component if it's a receiver.
+func receiveLayout(pipeID string, component interface{}, layout ChannelLayout) error {
+	if v, ok := component.(LayoutReceiver); ok {
+		return v.ReceiveLayout(pipeID, layout)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package pipe_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/internal/mock"
+)
+
+func TestChannelLayout(t *testing.T) {
+	tests := []struct {
+		numChannels int
+		expected    pipe.ChannelLayout
+		str         string
+	}{
+		{
+			numChannels: 1,
+			expected:    pipe.LayoutMono,
+			str:         "[FC]",
+		},
+		{
+			numChannels: 2,
+			expected:    pipe.LayoutStereo,
+			str:         "[FL FR]",
+		},
+		{
+			numChannels: 6,
+			expected:    pipe.Layout51,
+			str:         "[FL FR FC LFE BL BR]",
+		},
+		{
+			numChannels: 3,
+			expected:    pipe.ChannelLayout{pipe.PositionUnknown, pipe.PositionUnknown, pipe.PositionUnknown},
+			str:         "[Unknown Unknown Unknown]",
+		},
+	}
+	for _, test := range tests {
+		layout := pipe.DefaultLayout(test.numChannels)
+		assert.True(t, test.expected.Equal(layout))
+		assert.Equal(t, test.numChannels, layout.NumChannels())
+		assert.Equal(t, test.str, layout.String())
+	}
+
+	// default layouts are not shared
+	layout := pipe.DefaultLayout(2)
+	layout[0] = pipe.BackLeft
+	assert.Equal(t, pipe.FrontLeft, pipe.LayoutStereo[0])
+	assert.Equal(t, pipe.FrontLeft, pipe.DefaultLayout(2)[0])
+
+	assert.Equal(t, 3, pipe.Layout51.Index(pipe.LowFrequency))
+	assert.Equal(t, -1, pipe.LayoutStereo.Index(pipe.FrontCenter))
+	assert.False(t, pipe.LayoutQuad.Equal(pipe.LayoutAmbisonic))
+}
+
+func TestLayoutBinding(t *testing.T) {
+	pump := &mock.Pump{
+		Limit:         bufferSize,
+		NumChannels:   4,
+		ChannelLayout: pipe.LayoutAmbisonic,
+	}
+	proc := &mock.Processor{}
+	remixer := &mock.Remixer{
+		OutputNumChannels: 2,
+	}
+	sink := &mock.Sink{}
+
+	l, err := pipe.New(
+		&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(proc, remixer),
+			Sinks:      pipe.Sinks(sink),
+		},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, pipe.LayoutAmbisonic, proc.ReceivedLayout())
+	assert.Equal(t, pipe.LayoutAmbisonic, remixer.ReceivedLayout())
+	assert.Equal(t, pipe.LayoutStereo, sink.ReceivedLayout())
+	assert.Nil(t, pipe.Wait(l.Run(context.Background(), bufferSize)))
+	pipe.Wait(l.Close())
+
+	// reported layout doesn't match number of channels
+	pump.NumChannels = 2
+	_, err = pipe.New(
+		&pipe.Line{
+			Pump:  pump,
+			Sinks: pipe.Sinks(sink),
+		},
+	)
+	assert.Error(t, err)
+}
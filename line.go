@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"pipelined.dev/pipe/internal/runner"
+	"pipelined.dev/pipe/meta"
 	"pipelined.dev/signal"
 )
 
@@ -23,14 +24,16 @@ type (
 	// Processor should return output in the same signal buffer as input.
 	// It is encouraged to implement in-place processing algorithms.
 	// Buffer size could be changed during execution, but only decrease allowed.
-	// Number of channels cannot be changed.
+	// Number of channels cannot be changed. Use Resampler to change sample rate
+	// and Remixer to change number of channels.
 	Processor interface {
 		Process(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error)
 	}
 
 	// Sink is an interface for final stage in audio pipeline.
 	// This components must not change buffer content. Line can have
-	// multiple sinks and this will cause race condition.
+	// multiple sinks and this will cause race condition. Sinks that need
+	// to change buffer should implement Mutator.
 	Sink interface {
 		Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error)
 	}
@@ -55,13 +58,99 @@ type (
 	Flusher interface {
 		Flush(string) error
 	}
+
+	// Resampler is a processor that changes the sample rate of the signal.
+	// Resample is used to bind it instead of Process. It returns the output
+	// sample rate, all components after resampler are bound with it.
+	// Returned function receives the input buffer and the output buffer,
+	// which size is recalculated with respect to the output sample rate.
+	// If function produces less samples, output buffer should be trimmed.
+	Resampler interface {
+		Processor
+		Resample(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(in, out signal.Float64) error, signal.SampleRate, error)
+	}
+
+	// Remixer is a processor that changes the number of channels of the
+	// signal. Remix is used to bind it instead of Process. It returns the
+	// output number of channels, all components after remixer are bound
+	// with it. Returned function receives the input buffer and the output
+	// buffer of the same size with output number of channels.
+	Remixer interface {
+		Processor
+		Remix(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(in, out signal.Float64) error, int, error)
+	}
+
+	// MetaPump is a pump that has access to the metadata of buffers.
+	// MetaPump is used to bind it instead of Pump. Position and timestamp
+	// are set before the call, pump can attach custom tags and events.
+	MetaPump interface {
+		Pump
+		MetaPump(pipeID string) (func(signal.Float64, *meta.Data) error, signal.SampleRate, int, error)
+	}
+
+	// MetaProcessor is a processor that has access to the metadata of
+	// buffers. MetaProcess is used to bind it instead of Process. It can
+	// read events of upstream components and add new ones.
+	MetaProcessor interface {
+		Processor
+		MetaProcess(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64, *meta.Data) error, error)
+	}
+
+	// MetaSink is a sink that has access to the metadata of buffers.
+	// MetaSink is used to bind it instead of Sink. Metadata is shared
+	// between sinks and must not be changed.
+	MetaSink interface {
+		Sink
+		MetaSink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64, *meta.Data) error, error)
+	}
+
+	// ChannelProcessor is a processor that processes channels
+	// independently. ProcessChannel is used to bind it instead of
+	// Process, it's called for each channel. Channels of a buffer are
+	// processed concurrently by a bounded pool of workers, so closures
+	// of different channels must not share state.
+	ChannelProcessor interface {
+		Processor
+		ProcessChannel(pipeID string, sampleRate signal.SampleRate, channel int) (func([]float64) error, error)
+	}
+
+	// SilenceSkipper is a processor that produces silence for silent
+	// input. Such processor is skipped for silent buffers once its tail is
+	// over. SilenceTail returns the number of samples that processor keeps
+	// producing signal after the input became silent, reverbs and delays
+	// for example. It's called once the processor is bound.
+	SilenceSkipper interface {
+		Processor
+		SilenceTail(pipeID string) int
+	}
+
+	// Mutator is a sink that changes the content of buffer. If Mutates
+	// returns true, sink receives a private buffer: a copy if other sinks
+	// share the buffer, the buffer itself otherwise.
+	Mutator interface {
+		Mutates() bool
+	}
+
+	// LayoutReporter is a pump or remixer that reports the channel layout
+	// of its output. If component doesn't implement it or returns nil,
+	// DefaultLayout for the number of channels is used.
+	LayoutReporter interface {
+		Layout(pipeID string) ChannelLayout
+	}
+
+	// LayoutReceiver is a processor or sink that receives the channel
+	// layout of its input. ReceiveLayout is called before component is
+	// bound, so the layout is known when Process or Sink is called.
+	LayoutReceiver interface {
+		ReceiveLayout(pipeID string, layout ChannelLayout) error
+	}
 )
 
 // newUID returns new unique id value.
 func newUID() string {
 	b := make([]byte, 16)
 	rand.Read(b)
-	return fmt.Sprintf("%x-%x-%x-%x-%x\n", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
 }
 
 // Line is a sound processing sequence of components.
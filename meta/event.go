@@ -0,0 +1,62 @@
+package meta
+
+import "sort"
+
+// Event is a time-stamped event transported along with the signal.
+type Event struct {
+	// Offset is the position of event inside the buffer in samples.
+	Offset int
+	// Value carries event data, e.g. Marker or NoteOn.
+	Value interface{}
+}
+
+type (
+	// Marker is a cue point in the stream.
+	Marker struct {
+		Label string
+	}
+
+	// NoteOn starts the note.
+	NoteOn struct {
+		Channel  uint8
+		Key      uint8
+		Velocity uint8
+	}
+
+	// NoteOff stops the note.
+	NoteOff struct {
+		Channel  uint8
+		Key      uint8
+		Velocity uint8
+	}
+
+	// Onset is a detected start of a sound event.
+	Onset struct {
+		Strength float64
+	}
+)
+
+// AddEvent inserts a new event keeping events ordered by offset. Events
+// with the same offset are kept in the order they were added.
+func (d *Data) AddEvent(offset int, value interface{}) {
+	i := sort.Search(len(d.Events), func(i int) bool {
+		return d.Events[i].Offset > offset
+	})
+	d.Events = append(d.Events, Event{})
+	copy(d.Events[i+1:], d.Events[i:])
+	d.Events[i] = Event{
+		Offset: offset,
+		Value:  value,
+	}
+}
+
+// ScaleEvents recalculates event offsets when buffer size is changed,
+// for example by resampling.
+func (d *Data) ScaleEvents(from, to int) {
+	if from == to || from == 0 {
+		return
+	}
+	for i := range d.Events {
+		d.Events[i].Offset = d.Events[i].Offset * to / from
+	}
+}
@@ -0,0 +1,43 @@
+// Package meta provides metadata that is transported along with signal
+// buffers through the pipe.
+package meta
+
+import "time"
+
+// Data is the metadata of a single buffer. Pumps and processors can
+// modify it, sinks share the same data and must only read it.
+type Data struct {
+	// Position is the absolute offset of the first sample of the buffer
+	// in the stream. It's measured in samples of the current sample rate.
+	Position int64
+	// Timestamp is the time when pump produced the buffer. It contains
+	// a monotonic clock reading.
+	Timestamp time.Time
+	// Tags are custom values attached to the buffer.
+	Tags Tags
+	// Events are ordered by their offset inside the buffer.
+	Events []Event
+	// Silent is set when all samples of the buffer are zero. Buffers are
+	// allocated zeroed, so pumps can set it without filling the buffer.
+	// It's cleared before processor is called, so processors that keep
+	// the buffer silent must set it again.
+	Silent bool
+}
+
+// Tags is the set of custom values mapped to their keys.
+type Tags map[string]interface{}
+
+// SetTag attaches value to the buffer. Tags are allocated when the first
+// value is set.
+func (d *Data) SetTag(key string, value interface{}) {
+	if d.Tags == nil {
+		d.Tags = make(Tags)
+	}
+	d.Tags[key] = value
+}
+
+// Tag returns the value attached to the buffer.
+func (d *Data) Tag(key string) (interface{}, bool) {
+	v, ok := d.Tags[key]
+	return v, ok
+}
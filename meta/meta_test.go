@@ -0,0 +1,42 @@
+package meta_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe/meta"
+)
+
+func TestTags(t *testing.T) {
+	var d meta.Data
+	_, ok := d.Tag("timecode")
+	assert.False(t, ok)
+
+	d.SetTag("timecode", "00:00:01:00")
+	v, ok := d.Tag("timecode")
+	assert.True(t, ok)
+	assert.Equal(t, "00:00:01:00", v)
+	assert.Equal(t, 1, len(d.Tags))
+}
+
+func TestEvents(t *testing.T) {
+	var d meta.Data
+	d.AddEvent(10, meta.Marker{Label: "second"})
+	d.AddEvent(0, meta.NoteOn{Key: 60, Velocity: 100})
+	d.AddEvent(10, meta.Marker{Label: "third"})
+	d.AddEvent(5, meta.Onset{Strength: 0.5})
+
+	expected := []meta.Event{
+		{Offset: 0, Value: meta.NoteOn{Key: 60, Velocity: 100}},
+		{Offset: 5, Value: meta.Onset{Strength: 0.5}},
+		{Offset: 10, Value: meta.Marker{Label: "second"}},
+		{Offset: 10, Value: meta.Marker{Label: "third"}},
+	}
+	assert.Equal(t, expected, d.Events)
+
+	d.ScaleEvents(10, 20)
+	for i := range expected {
+		assert.Equal(t, expected[i].Offset*2, d.Events[i].Offset)
+	}
+}
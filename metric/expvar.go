@@ -0,0 +1,384 @@
+package metric
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Expvar is a recorder that keeps counters of component instances and
+// buffer pools. Counters can be published into expvar, component
+// counters are aggregated by component type.
+type Expvar struct {
+	sync.Mutex
+	m         map[string]*counters
+	pool      poolCounters
+	prefix    string              // prefix of published counters, empty if not published
+	published map[string]struct{} // published component types
+}
+
+// NewExpvar returns a new recorder. It's not published until Publish
+// is called, so recorders can be created for each pipe or test.
+func NewExpvar() *Expvar {
+	return &Expvar{
+		m: make(map[string]*counters),
+	}
+}
+
+// Publish publishes counters of component types into expvar. Each
+// counter is published as expvar.Func with "<prefix>.<type>.<counter>"
+// name once the first component of the type is added. Like
+// expvar.Publish, it panics if the name is already registered.
+func (e *Expvar) Publish(prefix string) {
+	e.Lock()
+	e.prefix = prefix
+	e.published = make(map[string]struct{})
+	var types []string
+	for _, c := range e.m {
+		if e.publish(c.componentType) {
+			types = append(types, c.componentType)
+		}
+	}
+	e.Unlock()
+	for _, componentType := range types {
+		e.publishType(prefix, componentType)
+	}
+}
+
+// Add implements Recorder.
+func (e *Expvar) Add(line, id, componentType string) ComponentRecorder {
+	c := &counters{
+		line:          line,
+		componentType: componentType,
+	}
+	e.Lock()
+	e.m[id] = c
+	publish, prefix := e.publish(componentType), e.prefix
+	e.Unlock()
+	// expvar calls published functions with its own lock held
+	if publish {
+		e.publishType(prefix, componentType)
+	}
+	return c
+}
+
+// publish marks component type as published and returns true if it
+// wasn't published yet. Must be called with lock held.
+func (e *Expvar) publish(componentType string) bool {
+	if e.published == nil {
+		return false
+	}
+	if _, ok := e.published[componentType]; ok {
+		return false
+	}
+	e.published[componentType] = struct{}{}
+	return true
+}
+
+// publishType publishes counters of component type. Counters of types
+// without components are null.
+func (e *Expvar) publishType(prefix, componentType string) {
+	for _, name := range counterNames {
+		name := name
+		expvar.Publish(fmt.Sprintf("%s.%s.%s", prefix, componentType, name), expvar.Func(func() interface{} {
+			e.Lock()
+			defer e.Unlock()
+			if v, ok := e.aggregate(componentType)[name]; ok {
+				return json.RawMessage(v)
+			}
+			return nil
+		}))
+	}
+}
+
+// Drop implements Recorder. Component recorders of dropped components
+// still work, but their values are not reported.
+func (e *Expvar) Drop(line string) {
+	e.Lock()
+	defer e.Unlock()
+	for id, c := range e.m {
+		if c.line == line {
+			delete(e.m, id)
+		}
+	}
+}
+
+// Get returns metrics values for provided component type. Counters of
+// all component instances of this type are summed up, latency and queue
+// are the highest values among instances.
+func (e *Expvar) Get(component interface{}) map[string]string {
+	e.Lock()
+	defer e.Unlock()
+	return e.aggregate(getType(component))
+}
+
+// GetAll returns counters for all measured component types.
+func (e *Expvar) GetAll() map[string]map[string]string {
+	e.Lock()
+	defer e.Unlock()
+	m := make(map[string]map[string]string)
+	for _, c := range e.m {
+		if _, ok := m[c.componentType]; !ok {
+			m[c.componentType] = e.aggregate(c.componentType)
+		}
+	}
+	return m
+}
+
+// GetComponent returns metrics values of component instance with
+// provided id. Values are labeled with the line and type of component.
+// Nil is returned if component is not measured.
+func (e *Expvar) GetComponent(id string) map[string]string {
+	e.Lock()
+	defer e.Unlock()
+	if c, ok := e.m[id]; ok {
+		return c.values()
+	}
+	return nil
+}
+
+// GetLine returns metrics values of all component instances of the line
+// mapped to their ids.
+func (e *Expvar) GetLine(line string) map[string]map[string]string {
+	e.Lock()
+	defer e.Unlock()
+	m := make(map[string]map[string]string)
+	for id, c := range e.m {
+		if c.line == line {
+			m[id] = c.values()
+		}
+	}
+	return m
+}
+
+// aggregate returns values of all instances of component type. Must be
+// called with lock held.
+func (e *Expvar) aggregate(componentType string) map[string]string {
+	var (
+		instances         int
+		messages, samples int64
+		latency, duration time.Duration
+		queue, processing time.Duration
+		calls             histogram
+	)
+	for _, c := range e.m {
+		if c.componentType != componentType {
+			continue
+		}
+		instances++
+		messages += c.messages.Value()
+		samples += c.samples.Value()
+		duration += c.duration.value()
+		processing += c.processing.value()
+		calls.merge(&c.calls)
+		if v := c.latency.value(); v > latency {
+			latency = v
+		}
+		if v := c.queue.value(); v > queue {
+			queue = v
+		}
+	}
+	if instances == 0 {
+		return map[string]string{}
+	}
+	return map[string]string{
+		ComponentCounter:      fmt.Sprint(instances),
+		MessageCounter:        fmt.Sprint(messages),
+		SampleCounter:         fmt.Sprint(samples),
+		LatencyCounter:        durationString(latency),
+		DurationCounter:       durationString(duration),
+		QueueCounter:          durationString(queue),
+		ProcessingCounter:     durationString(processing),
+		ProcessingP50Counter:  durationString(calls.quantile(0.5)),
+		ProcessingP95Counter:  durationString(calls.quantile(0.95)),
+		ProcessingP99Counter:  durationString(calls.quantile(0.99)),
+		ProcessingMaxCounter:  durationString(calls.maximum()),
+		RealTimeFactorCounter: realTimeFactorString(processing, duration),
+	}
+}
+
+// RecordLevels implements LevelRecorder. Levels of each channel are
+// kept until the next interval, clips are summed up.
+func (e *Expvar) RecordLevels(id string, levels []Level) {
+	e.Lock()
+	defer e.Unlock()
+	c, ok := e.m[id]
+	if !ok {
+		return
+	}
+	if len(c.levels) != len(levels) {
+		c.levels = make([]Level, len(levels))
+	}
+	for i, l := range levels {
+		l.Clips += c.levels[i].Clips
+		c.levels[i] = l
+	}
+}
+
+// Levels returns signal levels of each channel of component instance
+// with provided id. Nil is returned if levels are not recorded.
+func (e *Expvar) Levels(id string) []Level {
+	e.Lock()
+	defer e.Unlock()
+	if c, ok := e.m[id]; ok && c.levels != nil {
+		return append([]Level(nil), c.levels...)
+	}
+	return nil
+}
+
+// RealTimeFactor returns the processing time of all components of the
+// line divided by the duration of signal processed by the line. Line
+// that is executed in a single goroutine can keep up with live signal if
+// its factor is below 1.
+func (e *Expvar) RealTimeFactor(line string) float64 {
+	e.Lock()
+	defer e.Unlock()
+	var processing, duration time.Duration
+	for _, c := range e.m {
+		if c.line != line {
+			continue
+		}
+		processing += c.processing.value()
+		// all components process the same signal, but some might have
+		// processed more of it
+		if d := c.duration.value(); d > duration {
+			duration = d
+		}
+	}
+	return realTimeFactor(processing, duration)
+}
+
+// counterNames are names of published counters.
+var counterNames = []string{
+	ComponentCounter,
+	MessageCounter,
+	SampleCounter,
+	LatencyCounter,
+	DurationCounter,
+	QueueCounter,
+	ProcessingCounter,
+	ProcessingP50Counter,
+	ProcessingP95Counter,
+	ProcessingP99Counter,
+	ProcessingMaxCounter,
+	RealTimeFactorCounter,
+}
+
+// counters of a single component instance.
+type counters struct {
+	line          string
+	componentType string
+	messages      expvar.Int
+	samples       expvar.Int
+	latency       duration
+	duration      duration
+	queue         duration
+	processing    duration
+	calls         histogram
+	levels        []Level // guarded by recorder lock
+}
+
+// Record implements ComponentRecorder.
+func (c *counters) Record(m Measurement) {
+	c.latency.set(m.Latency)
+	c.messages.Add(1)
+	c.samples.Add(int64(m.Samples))
+	c.queue.set(m.Queue)
+	c.duration.add(m.Duration)
+	c.processing.add(m.Processing)
+	c.calls.record(m.Processing)
+}
+
+// values returns labels and counters of component instance. Must be
+// called with recorder lock held.
+func (c *counters) values() map[string]string {
+	return map[string]string{
+		LineLabel:             c.line,
+		TypeLabel:             c.componentType,
+		MessageCounter:        c.messages.String(),
+		SampleCounter:         c.samples.String(),
+		LatencyCounter:        c.latency.String(),
+		DurationCounter:       c.duration.String(),
+		QueueCounter:          c.queue.String(),
+		ProcessingCounter:     c.processing.String(),
+		ProcessingP50Counter:  durationString(c.calls.quantile(0.5)),
+		ProcessingP95Counter:  durationString(c.calls.quantile(0.95)),
+		ProcessingP99Counter:  durationString(c.calls.quantile(0.99)),
+		ProcessingMaxCounter:  durationString(c.calls.maximum()),
+		RealTimeFactorCounter: realTimeFactorString(c.processing.value(), c.duration.value()),
+		PeakCounter:           levelsString(c.levels, func(l Level) float64 { return l.Peak }),
+		RMSCounter:            levelsString(c.levels, func(l Level) float64 { return l.RMS }),
+		DCCounter:             levelsString(c.levels, func(l Level) float64 { return l.DC }),
+		ClipCounter:           levelsString(c.levels, func(l Level) float64 { return float64(l.Clips) }),
+	}
+}
+
+// duration allows to format time.Duration metric values.
+type duration struct {
+	d int64
+}
+
+func (v *duration) String() string {
+	return durationString(v.value())
+}
+
+func (v *duration) value() time.Duration {
+	return time.Duration(atomic.LoadInt64(&v.d))
+}
+
+func (v *duration) add(delta time.Duration) {
+	atomic.AddInt64(&v.d, int64(delta))
+}
+
+func (v *duration) set(value time.Duration) {
+	atomic.StoreInt64(&v.d, int64(value))
+}
+
+// realTimeFactor returns processing time divided by signal duration.
+// Zero is returned if no signal was processed.
+func realTimeFactor(processing, signal time.Duration) float64 {
+	if signal <= 0 {
+		return 0
+	}
+	return float64(processing) / float64(signal)
+}
+
+// realTimeFactorString formats real-time factor as JSON number.
+func realTimeFactorString(processing, signal time.Duration) string {
+	return strconv.FormatFloat(realTimeFactor(processing, signal), 'g', -1, 64)
+}
+
+// levelsString formats values of channel levels as JSON array. Values
+// that can't be represented in JSON are null.
+func levelsString(levels []Level, value func(Level) float64) string {
+	var b strings.Builder
+	b.WriteString("[")
+	for i := range levels {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(floatString(value(levels[i])))
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// floatString formats float as JSON number, null is returned for NaN
+// and infinities.
+func floatString(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "null"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// durationString formats duration as JSON string.
+func durationString(d time.Duration) string {
+	return fmt.Sprintf("\"%v\"", d)
+}
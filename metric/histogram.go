@@ -0,0 +1,102 @@
+package metric
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// histogramSubBuckets is the number of buckets that each power of two
+// is split into. Quantiles are reported with relative error below 25%.
+const histogramSubBuckets = 4
+
+// histogramBuckets is the number of buckets to cover any duration.
+const histogramBuckets = 64 * histogramSubBuckets
+
+// histogram counts durations in log-linear buckets. It's safe for
+// concurrent use and doesn't allocate on record.
+type histogram struct {
+	counts [histogramBuckets]uint64
+	total  uint64
+	max    int64
+}
+
+// record adds duration to the histogram.
+func (h *histogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	atomic.AddUint64(&h.counts[bucketOf(uint64(d))], 1)
+	atomic.AddUint64(&h.total, 1)
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&h.max, max, int64(d)) {
+			return
+		}
+	}
+}
+
+// quantile returns the upper bound of bucket that contains quantile q
+// of recorded durations. The result doesn't exceed the max duration.
+func (h *histogram) quantile(q float64) time.Duration {
+	total := atomic.LoadUint64(&h.total)
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+	max := h.maximum()
+	var count uint64
+	for i := range h.counts {
+		count += atomic.LoadUint64(&h.counts[i])
+		if count >= rank {
+			if upper := time.Duration(upperBound(i)); upper < max {
+				return upper
+			}
+			return max
+		}
+	}
+	return max
+}
+
+// merge adds counts of other histogram.
+func (h *histogram) merge(other *histogram) {
+	for i := range other.counts {
+		h.counts[i] += atomic.LoadUint64(&other.counts[i])
+	}
+	h.total += atomic.LoadUint64(&other.total)
+	if max := int64(other.maximum()); max > h.max {
+		h.max = max
+	}
+}
+
+// maximum returns the longest recorded duration.
+func (h *histogram) maximum() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.max))
+}
+
+// bucketOf returns the index of bucket for value. Values below
+// histogramSubBuckets have their own buckets, the rest are split into
+// histogramSubBuckets buckets per power of two.
+func bucketOf(v uint64) int {
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+	exp := bits.Len64(v) - 1 // at least 2
+	sub := int(v>>uint(exp-2)) & (histogramSubBuckets - 1)
+	return (exp-1)*histogramSubBuckets + sub
+}
+
+// upperBound returns the highest value of bucket.
+func upperBound(i int) uint64 {
+	if i < histogramSubBuckets {
+		return uint64(i)
+	}
+	exp := uint(i/histogramSubBuckets + 1)
+	sub := uint64(i % histogramSubBuckets)
+	lower := (histogramSubBuckets + sub) << (exp - 2)
+	return lower + 1<<(exp-2) - 1
+}
@@ -0,0 +1,107 @@
+package metric
+
+import (
+	"math"
+	"time"
+
+	"pipelined.dev/signal"
+)
+
+type (
+	// Level holds signal levels of a single channel of component
+	// output. Values are relative to full scale, so 1 is 0 dBFS.
+	Level struct {
+		Peak  float64 // highest absolute sample value
+		RMS   float64 // root mean square of samples
+		DC    float64 // mean of samples
+		Clips int64   // number of samples above full scale
+	}
+
+	// LevelRecorder is implemented by recorders that receive signal
+	// levels of component output. RecordLevels is called once per
+	// metering interval with levels of each channel measured in this
+	// interval. Levels slice is reused by the meter, so recorders must
+	// copy it.
+	LevelRecorder interface {
+		RecordLevels(id string, levels []Level)
+	}
+
+	// LevelResetFunc returns a new level meter for the run. Levels are
+	// recorded once per interval of signal.
+	LevelResetFunc func(interval time.Duration) *LevelMeter
+)
+
+// LevelMeter measures signal levels of component output. Samples are
+// added to the meter as they are processed and levels are passed to the
+// recorder after the buffer that completes the interval. It doesn't
+// allocate and must not be used concurrently.
+type LevelMeter struct {
+	recorder LevelRecorder
+	id       string
+	interval int // number of samples per channel in interval
+	size     int // number of samples per channel added in interval
+	peak     []float64
+	sum      []float64
+	squares  []float64
+	clips    []int64
+	levels   []Level
+}
+
+// Levels returns a level meter constructor for component instance with
+// provided id. Nil is returned if recorder doesn't implement
+// LevelRecorder.
+func Levels(r Recorder, id string, sampleRate signal.SampleRate, numChannels int) LevelResetFunc {
+	lr, ok := r.(LevelRecorder)
+	if !ok {
+		return nil
+	}
+	return func(interval time.Duration) *LevelMeter {
+		size := sampleRate.SamplesIn(interval)
+		if size < 1 {
+			size = 1
+		}
+		return &LevelMeter{
+			recorder: lr,
+			id:       id,
+			interval: size,
+			peak:     make([]float64, numChannels),
+			sum:      make([]float64, numChannels),
+			squares:  make([]float64, numChannels),
+			clips:    make([]int64, numChannels),
+			levels:   make([]Level, numChannels),
+		}
+	}
+}
+
+// Add adds sample value of the channel.
+func (l *LevelMeter) Add(channel int, v float64) {
+	l.sum[channel] += v
+	l.squares[channel] += v * v
+	v = math.Abs(v)
+	if v > l.peak[channel] {
+		l.peak[channel] = v
+	}
+	if v > 1 {
+		l.clips[channel]++
+	}
+}
+
+// Advance completes the buffer of provided size. Levels are recorded if
+// the interval is complete.
+func (l *LevelMeter) Advance(size int) {
+	l.size += size
+	if l.size < l.interval {
+		return
+	}
+	for i := range l.levels {
+		l.levels[i] = Level{
+			Peak:  l.peak[i],
+			RMS:   math.Sqrt(l.squares[i] / float64(l.size)),
+			DC:    l.sum[i] / float64(l.size),
+			Clips: l.clips[i],
+		}
+		l.peak[i], l.sum[i], l.squares[i], l.clips[i] = 0, 0, 0, 0
+	}
+	l.size = 0
+	l.recorder.RecordLevels(l.id, l.levels)
+}
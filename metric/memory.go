@@ -0,0 +1,210 @@
+package metric
+
+import (
+	"sort"
+	"sync"
+)
+
+// MaxMeasurements is the number of the latest measurements and levels
+// that Memory keeps for each component instance.
+const MaxMeasurements = 1024
+
+// Memory is a recorder that keeps measurements of component instances
+// and counters of buffer pools in memory. It's meant to be used in
+// tests, where metrics of a single pipe are asserted in isolation. Only
+// the latest MaxMeasurements of each component are kept, but snapshots
+// include totals of all measurements.
+type Memory struct {
+	sync.Mutex
+	m    map[string]*memoryComponent
+	pool poolCounters
+}
+
+// memoryComponent keeps measurements of a single component instance.
+type memoryComponent struct {
+	*Memory
+	line          string
+	componentType string
+	measurements  []Measurement
+	next          int // index of the oldest measurement once it's full
+	levels        [][]Level
+	nextLevels    int // index of the oldest levels once it's full
+	clips         []int64 // clips of all levels of each channel
+	messages      int64
+	total         Measurement // samples, duration and processing of all measurements
+	calls         histogram
+}
+
+// NewMemory returns a new in-memory recorder.
+func NewMemory() *Memory {
+	return &Memory{
+		m: make(map[string]*memoryComponent),
+	}
+}
+
+// Add implements Recorder.
+func (m *Memory) Add(line, id, componentType string) ComponentRecorder {
+	c := &memoryComponent{
+		Memory:        m,
+		line:          line,
+		componentType: componentType,
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.m[id] = c
+	return c
+}
+
+// Drop implements Recorder.
+func (m *Memory) Drop(line string) {
+	m.Lock()
+	defer m.Unlock()
+	for id, c := range m.m {
+		if c.line == line {
+			delete(m.m, id)
+		}
+	}
+}
+
+// Components returns ids of registered component instances of the
+// line.
+func (m *Memory) Components(line string) []string {
+	m.Lock()
+	defer m.Unlock()
+	var ids []string
+	for id, c := range m.m {
+		if c.line == line {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Labels returns the line and type of component instance with provided
+// id. False is returned if component is not registered.
+func (m *Memory) Labels(id string) (line, componentType string, ok bool) {
+	m.Lock()
+	defer m.Unlock()
+	if c, ok := m.m[id]; ok {
+		return c.line, c.componentType, true
+	}
+	return "", "", false
+}
+
+// Measurements returns a copy of the latest measurements of component
+// instance with provided id in the order they were recorded.
+func (m *Memory) Measurements(id string) []Measurement {
+	m.Lock()
+	defer m.Unlock()
+	c, ok := m.m[id]
+	if !ok {
+		return nil
+	}
+	return append(append([]Measurement(nil), c.measurements[c.next:]...), c.measurements[:c.next]...)
+}
+
+// Levels returns a copy of the latest signal levels of component
+// instance with provided id in the order they were recorded.
+func (m *Memory) Levels(id string) [][]Level {
+	m.Lock()
+	defer m.Unlock()
+	c, ok := m.m[id]
+	if !ok {
+		return nil
+	}
+	return append(append([][]Level(nil), c.levels[c.nextLevels:]...), c.levels[:c.nextLevels]...)
+}
+
+// RecordLevels implements LevelRecorder.
+func (m *Memory) RecordLevels(id string, levels []Level) {
+	m.Lock()
+	defer m.Unlock()
+	c, ok := m.m[id]
+	if !ok {
+		return
+	}
+	levels = append([]Level(nil), levels...)
+	if len(c.clips) != len(levels) {
+		c.clips = make([]int64, len(levels))
+	}
+	for i := range levels {
+		c.clips[i] += levels[i].Clips
+	}
+	if len(c.levels) < MaxMeasurements {
+		c.levels = append(c.levels, levels)
+		return
+	}
+	c.levels[c.nextLevels] = levels
+	c.nextLevels = (c.nextLevels + 1) % MaxMeasurements
+}
+
+// Snapshot implements Snapshotter. Latency and queue are the values of
+// the latest measurement, levels are the latest recorded ones with clips
+// summed up. Snapshots are sorted by id.
+func (m *Memory) Snapshot() []Snapshot {
+	m.Lock()
+	defer m.Unlock()
+	snapshots := make([]Snapshot, 0, len(m.m))
+	for id, c := range m.m {
+		s := Snapshot{
+			ID:            id,
+			Line:          c.line,
+			Type:          c.componentType,
+			Messages:      c.messages,
+			Samples:       int64(c.total.Samples),
+			Duration:      c.total.Duration,
+			Processing:    c.total.Processing,
+			ProcessingP50: c.calls.quantile(0.5),
+			ProcessingP95: c.calls.quantile(0.95),
+			ProcessingP99: c.calls.quantile(0.99),
+			ProcessingMax: c.calls.maximum(),
+		}
+		if n := len(c.measurements); n > 0 {
+			latest := c.measurements[(c.next+n-1)%n]
+			s.Latency, s.Queue = latest.Latency, latest.Queue
+		}
+		if n := len(c.levels); n > 0 {
+			s.Levels = append([]Level(nil), c.levels[(c.nextLevels+n-1)%n]...)
+			for i := range s.Levels {
+				s.Levels[i].Clips = c.clips[i]
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots
+}
+
+// GetPool returns counters of buffer pools.
+func (m *Memory) GetPool() map[string]string {
+	return m.pool.values()
+}
+
+// PoolAlloc implements PoolRecorder.
+func (m *Memory) PoolAlloc(miss bool) {
+	m.pool.alloc(miss)
+}
+
+// PoolFree implements PoolRecorder.
+func (m *Memory) PoolFree() {
+	m.pool.free()
+}
+
+// Record implements ComponentRecorder.
+func (c *memoryComponent) Record(measurement Measurement) {
+	c.Lock()
+	defer c.Unlock()
+	c.messages++
+	c.total.Samples += measurement.Samples
+	c.total.Duration += measurement.Duration
+	c.total.Processing += measurement.Processing
+	c.calls.record(measurement.Processing)
+	if len(c.measurements) < MaxMeasurements {
+		c.measurements = append(c.measurements, measurement)
+		return
+	}
+	c.measurements[c.next] = measurement
+	c.next = (c.next + 1) % MaxMeasurements
+}
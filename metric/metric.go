@@ -1,11 +1,7 @@
 package metric
 
 import (
-	"expvar"
-	"fmt"
 	"reflect"
-	"sync"
-	"sync/atomic"
 	"time"
 
 	"pipelined.dev/signal"
@@ -22,129 +18,179 @@ const (
 	LatencyCounter = "Latency"
 	// DurationCounter counts what's the duration of signal.
 	DurationCounter = "Duration"
-	// ComponentCounter counts number of calls.
+	// ComponentCounter counts number of components.
 	ComponentCounter = "Components"
+	// QueueCounter measures the duration of signal that can be queued
+	// between component and the next stage.
+	QueueCounter = "Queue"
+	// ProcessingCounter measures the total time spent inside component
+	// calls.
+	ProcessingCounter = "Processing"
+	// ProcessingP50Counter is the median time of component call.
+	ProcessingP50Counter = "ProcessingP50"
+	// ProcessingP95Counter is the 95th percentile time of component call.
+	ProcessingP95Counter = "ProcessingP95"
+	// ProcessingP99Counter is the 99th percentile time of component call.
+	ProcessingP99Counter = "ProcessingP99"
+	// ProcessingMaxCounter is the longest time of component call.
+	ProcessingMaxCounter = "ProcessingMax"
+	// RealTimeFactorCounter is the processing time divided by the
+	// duration of processed signal. Component can keep up with live
+	// signal if its factor is below 1.
+	RealTimeFactorCounter = "RealTimeFactor"
+	// PeakCounter is the peak level of each channel of component output
+	// in the last metering interval.
+	PeakCounter = "Peak"
+	// RMSCounter is the RMS level of each channel of component output in
+	// the last metering interval.
+	RMSCounter = "RMS"
+	// DCCounter is the DC offset of each channel of component output in
+	// the last metering interval.
+	DCCounter = "DC"
+	// ClipCounter counts samples above full scale in each channel of
+	// component output.
+	ClipCounter = "Clips"
 )
 
-var (
-	components = metrics{
-		m: make(map[string]metric),
+const (
+	// LineLabel is the id of the line that contains component.
+	LineLabel = "Line"
+	// TypeLabel is the type of component.
+	TypeLabel = "Type"
+)
+
+// Default is the recorder that publishes metrics into expvar. It's
+// used by pipes unless other recorder is provided. Component counters
+// are published as "pipe.components.<type>.<counter>" and pool counters
+// with "pipe.pool" prefix.
+var Default = NewExpvar()
+
+func init() {
+	Default.Publish(componentsLabel)
+	Default.PublishPool(poolLabel)
+}
+
+type (
+	// Recorder is the backend that receives metrics of components.
+	// Recorders must be safe for concurrent use.
+	Recorder interface {
+		// Add registers component instance with provided id and returns
+		// the recorder of its measurements. Line is the id of the line
+		// that contains component.
+		Add(line, id, componentType string) ComponentRecorder
+		// Drop removes metrics of all component instances of the line.
+		Drop(line string)
 	}
 
-	counters = []string{
-		MessageCounter,
-		SampleCounter,
-		LatencyCounter,
-		DurationCounter,
-		ComponentCounter,
+	// ComponentRecorder receives measurements of a single component
+	// instance. Record is called for every processed buffer, so it
+	// should not block or allocate.
+	ComponentRecorder interface {
+		Record(Measurement)
+	}
+
+	// PoolRecorder is implemented by recorders that count buffers of
+	// pools used by the run. It's called for every allocated and
+	// released buffer, so it should not block or allocate.
+	PoolRecorder interface {
+		// PoolAlloc captures allocation of buffer from the pool. Miss is
+		// true if pool had no free buffer and created a new one.
+		PoolAlloc(miss bool)
+		// PoolFree captures release of buffer into the pool.
+		PoolFree()
+	}
+
+	// Measurement holds metrics of a single buffer processed by
+	// component.
+	Measurement struct {
+		Samples    int           // number of samples in buffer
+		Duration   time.Duration // duration of signal in buffer
+		Latency    time.Duration // time since the previous call
+		Queue      time.Duration // duration of signal that can be queued after component
+		Processing time.Duration // time spent inside component call
 	}
 )
 
-// Get metrics values for provided component type.
+// Get returns metrics values of default recorder for provided component
+// type.
 func Get(component interface{}) map[string]string {
-	return getCounters(getType(component))
+	return Default.Get(component)
 }
 
-// GetAll returns counters for all measured components.
+// GetAll returns counters of default recorder for all measured
+// component types.
 func GetAll() map[string]map[string]string {
-	m := make(map[string]map[string]string)
-	components.Lock()
-	defer components.Unlock()
-	for component := range components.m {
-		m[component] = getCounters(component)
-	}
-	return m
+	return Default.GetAll()
 }
 
-func getCounters(componentType string) map[string]string {
-	m := make(map[string]string)
-	for _, counter := range counters {
-		v := expvar.Get(key(componentType, counter))
-		if v != nil {
-			m[counter] = v.String()
-		}
-	}
-	return m
+// GetComponent returns metrics values of default recorder for component
+// instance with provided id.
+func GetComponent(id string) map[string]string {
+	return Default.GetComponent(id)
+}
+
+// GetLine returns metrics values of default recorder for all component
+// instances of the line.
+func GetLine(line string) map[string]map[string]string {
+	return Default.GetLine(line)
+}
+
+// GetRealTimeFactor returns the real-time factor of the line in default
+// recorder.
+func GetRealTimeFactor(line string) float64 {
+	return Default.RealTimeFactor(line)
+}
+
+// GetLevels returns signal levels of component instance with provided
+// id from default recorder.
+func GetLevels(id string) []Level {
+	return Default.Levels(id)
+}
+
+// Drop removes metrics of all component instances of the line from
+// default recorder.
+func Drop(line string) {
+	Default.Drop(line)
 }
 
 // ResetFunc returns new Measure closure. This closure is needed to postpone metrics
-// capture until component is actually running.
-type ResetFunc func() MeasureFunc
-
-// MeasureFunc captures metrics when buffer is processed.
-type MeasureFunc func(bufferSize int)
-
-// Meter creates new meter closure to capture component counters.
-func Meter(component interface{}, sampleRate signal.SampleRate) ResetFunc {
-	t := getType(component)
-	metric := components.get(t)
-	metric.components.Add(1)
-	return func() MeasureFunc {
+// capture until component is actually running. Depth is the number of
+// buffers that can be queued between component and the next stage.
+type ResetFunc func(depth int) MeasureFunc
+
+// MeasureFunc captures metrics when buffer is processed. Processing is
+// the time spent inside component call.
+type MeasureFunc func(bufferSize int, processing time.Duration)
+
+// Meter registers component instance with provided id in the recorder
+// and creates new meter closure to capture its counters. Line is the id
+// of the line that contains component.
+func Meter(r Recorder, line, id string, component interface{}, sampleRate signal.SampleRate) ResetFunc {
+	cr := r.Add(line, id, getType(component))
+	return func(depth int) MeasureFunc {
 		calledAt := time.Now()
 		var (
 			bufferSize     int
 			bufferDuration time.Duration
 		)
-		return func(s int) {
-			metric.latency.set(time.Since(calledAt))
-			metric.messages.Add(1)
-			metric.samples.Add(int64(s))
+		return func(s int, processing time.Duration) {
 			// recalculate buffer duration only when buffer size has changed
 			if bufferSize != s {
 				bufferSize = s
 				bufferDuration = sampleRate.DurationOf(s)
 			}
-			metric.duration.add(bufferDuration)
+			cr.Record(Measurement{
+				Samples:    s,
+				Duration:   bufferDuration,
+				Latency:    time.Since(calledAt),
+				Queue:      time.Duration(depth) * bufferDuration,
+				Processing: processing,
+			})
 			calledAt = time.Now()
 		}
 	}
 }
 
-type metrics struct {
-	sync.Mutex
-	m map[string]metric
-}
-
-func (m *metrics) get(componentType string) metric {
-	m.Lock()
-	defer m.Unlock()
-	if metric, ok := m.m[componentType]; ok {
-		// return existing metric if available
-		return metric
-	}
-	// create new metric
-	metric := newMetric(componentType)
-	m.m[componentType] = metric
-	return metric
-}
-
-type metric struct {
-	key        string
-	components *expvar.Int
-	messages   *expvar.Int
-	samples    *expvar.Int
-	latency    *duration
-	duration   *duration
-}
-
-func newMetric(componentType string) metric {
-	m := metric{
-		key:        componentType,
-		components: expvar.NewInt(key(componentType, ComponentCounter)),
-		messages:   expvar.NewInt(key(componentType, MessageCounter)),
-		samples:    expvar.NewInt(key(componentType, SampleCounter)),
-		latency:    &duration{},
-		duration:   &duration{},
-	}
-	expvar.Publish(key(componentType, LatencyCounter), m.latency)
-	expvar.Publish(key(componentType, DurationCounter), m.duration)
-	return m
-}
-
-func key(componentType, counter string) string {
-	return fmt.Sprintf("%s.%s.%s", componentsLabel, componentType, counter)
-}
-
 func getType(component interface{}) string {
 	rv := reflect.ValueOf(component)
 	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
@@ -152,20 +198,3 @@ func getType(component interface{}) string {
 	}
 	return rv.Type().String()
 }
-
-// duration allows to format time.Duration metric values.
-type duration struct {
-	d int64
-}
-
-func (v *duration) String() string {
-	return fmt.Sprintf("\"%v\"", time.Duration(atomic.LoadInt64(&v.d)))
-}
-
-func (v *duration) add(delta time.Duration) {
-	atomic.AddInt64(&v.d, int64(delta))
-}
-
-func (v *duration) set(value time.Duration) {
-	atomic.StoreInt64(&v.d, int64(value))
-}
@@ -1,8 +1,14 @@
 package metric_test
 
 import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"math"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -11,64 +17,259 @@ import (
 )
 
 func TestMeter(t *testing.T) {
-	sampleRate := 44100
-	pint := 1
+	sampleRate := signal.SampleRate(44100)
+	type gain struct{}
 	// test cases
 	var tests = []struct {
-		component          interface{}
-		routines           int
-		buffers            int
-		bufferSize         int
-		expectedSamples    string
-		expectedComponents string
+		line       string
+		id         string
+		component  interface{}
+		buffers    int
+		bufferSize int
+		expected   map[string]string
 	}{
 		{
-			component:          int(1),
-			routines:           2,
-			buffers:            10,
-			bufferSize:         100,
-			expectedSamples:    "2000",
-			expectedComponents: "2",
+			line:       "line1",
+			id:         "component1",
+			component:  &gain{},
+			buffers:    10,
+			bufferSize: 100,
+			expected: map[string]string{
+				metric.LineLabel:     "line1",
+				metric.TypeLabel:     "metric_test.gain",
+				metric.SampleCounter: "1000",
+			},
 		},
 		{
-			component:          &pint,
-			routines:           2,
-			buffers:            10,
-			bufferSize:         100,
-			expectedSamples:    "4000",
-			expectedComponents: "4",
+			line:       "line2",
+			id:         "component2",
+			component:  &gain{},
+			buffers:    20,
+			bufferSize: 100,
+			expected: map[string]string{
+				metric.LineLabel:     "line2",
+				metric.TypeLabel:     "metric_test.gain",
+				metric.SampleCounter: "2000",
+			},
 		},
 		{
-			component:          "test",
-			routines:           2,
-			buffers:            10,
-			bufferSize:         100,
-			expectedSamples:    "2000",
-			expectedComponents: "2",
+			line:       "line2",
+			id:         "component3",
+			component:  "test",
+			buffers:    10,
+			bufferSize: 50,
+			expected: map[string]string{
+				metric.LineLabel:     "line2",
+				metric.TypeLabel:     "string",
+				metric.SampleCounter: "500",
+			},
 		},
 	}
-	// function to test meter.
-	testFn := func(fn metric.ResetFunc, wg *sync.WaitGroup, buffers int, bufferSize int) {
-		m := fn()
-		for i := 0; i < buffers; i++ {
-			m(bufferSize)
-		}
-		wg.Done()
+
+	var wg sync.WaitGroup
+	wg.Add(len(tests))
+	for _, c := range tests {
+		go func(fn metric.ResetFunc, buffers, bufferSize int) {
+			m := fn(2)
+			for i := 0; i < buffers; i++ {
+				m(bufferSize, time.Millisecond)
+			}
+			wg.Done()
+		}(metric.Meter(metric.Default, c.line, c.id, c.component, sampleRate), c.buffers, c.bufferSize)
 	}
+	// check if no data race.
+	wg.Wait()
 
 	for _, c := range tests {
-		wg := &sync.WaitGroup{}
-		wg.Add(c.routines)
-		for i := 0; i < c.routines; i++ {
-			go testFn(metric.Meter(c.component, signal.SampleRate(sampleRate)), wg, c.buffers, c.bufferSize)
+		values := metric.GetComponent(c.id)
+		for k, v := range c.expected {
+			assert.Equal(t, v, values[k])
 		}
-		// check if no data race.
-		wg.Wait()
-		values := metric.Get(c.component)
-		assert.Equal(t, c.expectedSamples, values[metric.SampleCounter])
-		assert.Equal(t, c.expectedComponents, values[metric.ComponentCounter])
+		queue := 2 * sampleRate.DurationOf(c.bufferSize)
+		assert.Equal(t, fmt.Sprintf("%q", queue), values[metric.QueueCounter])
+	}
+
+	// instances of the same type are summed up
+	values := metric.Get(&gain{})
+	assert.Equal(t, "3000", values[metric.SampleCounter])
+	assert.Equal(t, "2", values[metric.ComponentCounter])
+	assert.Equal(t, 2, len(metric.GetAll()))
+	assert.Equal(t, 2, len(metric.GetLine("line2")))
+
+	// counters are published for each type
+	assert.Equal(t, "2", expvar.Get("pipe.components.metric_test.gain.Components").String())
+	assert.Equal(t, "3000", expvar.Get("pipe.components.metric_test.gain.Samples").String())
+	assert.Equal(t, "500", expvar.Get("pipe.components.string.Samples").String())
+	for _, counter := range []string{metric.MessageCounter, metric.LatencyCounter, metric.ProcessingP99Counter} {
+		published := expvar.Get("pipe.components.metric_test.gain." + counter).String()
+		assert.True(t, json.Valid([]byte(published)), counter)
+	}
+	assert.Equal(t, values[metric.MessageCounter], expvar.Get("pipe.components.metric_test.gain.Messages").String())
+
+	metric.Drop("line2")
+	assert.Nil(t, metric.GetComponent("component2"))
+	assert.Equal(t, 0, len(metric.GetLine("line2")))
+	assert.Equal(t, "1", metric.Get(&gain{})[metric.ComponentCounter])
+	assert.Equal(t, 0, len(metric.Get("test")))
+	metric.Drop("line1")
+	assert.Equal(t, 0, len(metric.GetAll()))
+	// counters of dropped types are null
+	assert.Equal(t, "null", expvar.Get("pipe.components.string.Samples").String())
+}
+
+func TestProcessing(t *testing.T) {
+	recorder := metric.NewExpvar()
+	sampleRate := signal.SampleRate(44100)
+	// buffers of 10ms
+	fn := metric.Meter(recorder, "line", "component1", "test", sampleRate)(1)
+	for i := 1; i <= 100; i++ {
+		fn(441, time.Duration(i)*time.Microsecond)
+	}
+	metric.Meter(recorder, "line", "component2", "test", sampleRate)(1)(441, 50*time.Millisecond)
+
+	values := recorder.GetComponent("component1")
+	// quantiles are upper bounds of log-linear buckets
+	tests := []struct {
+		counter string
+		min     time.Duration
+		max     time.Duration
+	}{
+		{metric.ProcessingP50Counter, 50 * time.Microsecond, 63 * time.Microsecond},
+		{metric.ProcessingP95Counter, 95 * time.Microsecond, 100 * time.Microsecond},
+		{metric.ProcessingP99Counter, 99 * time.Microsecond, 100 * time.Microsecond},
+		{metric.ProcessingMaxCounter, 100 * time.Microsecond, 100 * time.Microsecond},
+		{metric.ProcessingCounter, 5050 * time.Microsecond, 5050 * time.Microsecond},
+	}
+	for _, test := range tests {
+		d, err := time.ParseDuration(strings.Trim(values[test.counter], `"`))
+		assert.Nil(t, err)
+		assert.True(t, test.min <= d && d <= test.max, "%s: %v", test.counter, d)
 	}
+	// 5.05ms of processing for 1s of signal
+	assert.Equal(t, "0.00505", values[metric.RealTimeFactorCounter])
+	// 55.05ms of processing for 1s of signal
+	assert.InDelta(t, 0.05505, recorder.RealTimeFactor("line"), 1e-9)
+	assert.Equal(t, `"50ms"`, recorder.Get("test")[metric.ProcessingMaxCounter])
+}
+
+func TestMemory(t *testing.T) {
+	sampleRate := signal.SampleRate(44100)
+	m := metric.NewMemory()
+	fn := metric.Meter(m, "line", "component", "test", sampleRate)(2)
+	fn(100, time.Millisecond)
+	fn(50, 2*time.Millisecond)
+	// metrics are not published
+	assert.Nil(t, metric.GetComponent("component"))
+
+	line, componentType, ok := m.Labels("component")
+	assert.True(t, ok)
+	assert.Equal(t, "line", line)
+	assert.Equal(t, "string", componentType)
+	assert.Equal(t, []string{"component"}, m.Components("line"))
+
+	measurements := m.Measurements("component")
+	assert.Equal(t, 2, len(measurements))
+	assert.Equal(t, 100, measurements[0].Samples)
+	assert.Equal(t, sampleRate.DurationOf(100), measurements[0].Duration)
+	assert.Equal(t, 2*sampleRate.DurationOf(100), measurements[0].Queue)
+	assert.Equal(t, 50, measurements[1].Samples)
+	assert.Equal(t, 2*sampleRate.DurationOf(50), measurements[1].Queue)
+
+	// only the latest measurements are kept
+	for i := 0; i < metric.MaxMeasurements; i++ {
+		fn(i, time.Millisecond)
+	}
+	measurements = m.Measurements("component")
+	assert.Equal(t, metric.MaxMeasurements, len(measurements))
+	assert.Equal(t, 0, measurements[0].Samples)
+	assert.Equal(t, metric.MaxMeasurements-1, measurements[metric.MaxMeasurements-1].Samples)
+
+	// snapshots include all measurements
+	m.RecordLevels("component", []metric.Level{{Peak: 2, Clips: 1}})
+	m.RecordLevels("component", []metric.Level{{Peak: 0.5, Clips: 2}})
+	snapshots := m.Snapshot()
+	assert.Equal(t, 1, len(snapshots))
+	snapshot := snapshots[0]
+	assert.Equal(t, "component", snapshot.ID)
+	assert.Equal(t, "string", snapshot.Type)
+	assert.Equal(t, int64(metric.MaxMeasurements+2), snapshot.Messages)
+	assert.Equal(t, int64(150+(metric.MaxMeasurements-1)*metric.MaxMeasurements/2), snapshot.Samples)
+	assert.Equal(t, time.Duration(metric.MaxMeasurements+3)*time.Millisecond, snapshot.Processing)
+	assert.Equal(t, 2*sampleRate.DurationOf(metric.MaxMeasurements-1), snapshot.Queue)
+	assert.Equal(t, []metric.Level{{Peak: 0.5, Clips: 3}}, snapshot.Levels)
+
+	// pool counters are kept in recorder
+	m.PoolAlloc(true)
+	m.PoolFree()
+	assert.Equal(t, "1", m.GetPool()[metric.MissCounter])
+	assert.Equal(t, "0", m.GetPool()[metric.OutstandingCounter])
+
+	m.Drop("line")
+	_, _, ok = m.Labels("component")
+	assert.False(t, ok)
+	assert.Nil(t, m.Measurements("component"))
+}
+
+func TestLevels(t *testing.T) {
+	recorder := metric.NewExpvar()
+	sampleRate := signal.SampleRate(100)
+	metric.Meter(recorder, "line", "component", "test", sampleRate)
+	// levels are recorded every 10 samples
+	l := metric.Levels(recorder, "component", sampleRate, 2)(100 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			l.Add(0, 0.5)
+		} else {
+			l.Add(0, -1.5)
+		}
+		l.Add(1, 0.25)
+		if i == 4 {
+			l.Advance(5)
+			// interval is not complete
+			assert.Nil(t, recorder.Levels("component"))
+		}
+	}
+	l.Advance(5)
+
+	levels := recorder.Levels("component")
+	assert.Equal(t, 2, len(levels))
+	assert.Equal(t, 1.5, levels[0].Peak)
+	assert.InDelta(t, math.Sqrt(1.25), levels[0].RMS, 1e-9)
+	assert.Equal(t, -0.5, levels[0].DC)
+	assert.Equal(t, int64(5), levels[0].Clips)
+	assert.Equal(t, metric.Level{Peak: 0.25, RMS: 0.25, DC: 0.25}, levels[1])
+
+	// clips are summed up
+	l.Add(0, 2)
+	l.Advance(10)
+	levels = recorder.Levels("component")
+	assert.Equal(t, 2.0, levels[0].Peak)
+	assert.Equal(t, int64(6), levels[0].Clips)
+	assert.Equal(t, metric.Level{}, levels[1])
+
+	values := recorder.GetComponent("component")
+	assert.Equal(t, "[2, 0]", values[metric.PeakCounter])
+	assert.Equal(t, "[6, 0]", values[metric.ClipCounter])
+
+	// recorders without levels don't meter them
+	assert.Nil(t, metric.Levels(struct{ metric.Recorder }{recorder}, "component", sampleRate, 2))
+}
+
+func TestPool(t *testing.T) {
+	// default recorder publishes pool counters
+	assert.Equal(t, 5, len(metric.GetPool()))
+	assert.NotNil(t, expvar.Get("pipe.pool.Allocs"))
+	assert.NotNil(t, expvar.Get("pipe.pool.HighWater"))
+
+	recorder := metric.NewExpvar()
+	recorder.PoolAlloc(true)
+	recorder.PoolAlloc(false)
+	recorder.PoolFree()
 
-	total := metric.GetAll()
-	assert.Equal(t, 2, len(total))
+	values := recorder.GetPool()
+	assert.Equal(t, "2", values[metric.AllocCounter])
+	assert.Equal(t, "1", values[metric.FreeCounter])
+	assert.Equal(t, "1", values[metric.MissCounter])
+	assert.Equal(t, "1", values[metric.OutstandingCounter])
+	assert.Equal(t, "2", values[metric.HighWaterCounter])
 }
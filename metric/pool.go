@@ -0,0 +1,125 @@
+package metric
+
+import (
+	"expvar"
+	"fmt"
+	"sync/atomic"
+)
+
+const poolLabel = "pipe.pool"
+
+const (
+	// AllocCounter measures number of buffers allocated from pools.
+	AllocCounter = "Allocs"
+	// FreeCounter measures number of buffers released into pools.
+	FreeCounter = "Frees"
+	// MissCounter measures number of allocations that created new buffers.
+	MissCounter = "Misses"
+	// OutstandingCounter measures number of buffers that are in use.
+	OutstandingCounter = "Outstanding"
+	// HighWaterCounter measures the highest number of buffers in use.
+	HighWaterCounter = "HighWater"
+)
+
+var poolCounterNames = []string{
+	AllocCounter,
+	FreeCounter,
+	MissCounter,
+	OutstandingCounter,
+	HighWaterCounter,
+}
+
+// GetPool returns counters of buffer pools of default recorder.
+func GetPool() map[string]string {
+	return Default.GetPool()
+}
+
+// GetPool returns counters of buffer pools.
+func (e *Expvar) GetPool() map[string]string {
+	return e.pool.values()
+}
+
+// PublishPool publishes counters of buffer pools into expvar. Each
+// counter is published with its own name prefixed with provided one.
+// Like expvar.Publish, it panics if the name is already registered.
+func (e *Expvar) PublishPool(prefix string) {
+	for _, name := range poolCounterNames {
+		expvar.Publish(fmt.Sprintf("%s.%s", prefix, name), e.pool.counter(name))
+	}
+}
+
+// PoolAlloc implements PoolRecorder.
+func (e *Expvar) PoolAlloc(miss bool) {
+	e.pool.alloc(miss)
+}
+
+// PoolFree implements PoolRecorder.
+func (e *Expvar) PoolFree() {
+	e.pool.free()
+}
+
+// poolCounters counts buffers of pools used by runs.
+type poolCounters struct {
+	allocs      expvar.Int
+	frees       expvar.Int
+	misses      expvar.Int
+	outstanding expvar.Int
+	highWater   highWater
+}
+
+func (p *poolCounters) alloc(miss bool) {
+	p.allocs.Add(1)
+	if miss {
+		p.misses.Add(1)
+	}
+	p.outstanding.Add(1)
+	p.highWater.update(p.outstanding.Value())
+}
+
+func (p *poolCounters) free() {
+	p.frees.Add(1)
+	p.outstanding.Add(-1)
+}
+
+// counter returns the counter with provided name.
+func (p *poolCounters) counter(name string) expvar.Var {
+	switch name {
+	case AllocCounter:
+		return &p.allocs
+	case FreeCounter:
+		return &p.frees
+	case MissCounter:
+		return &p.misses
+	case OutstandingCounter:
+		return &p.outstanding
+	default:
+		return &p.highWater
+	}
+}
+
+// values returns values of all counters.
+func (p *poolCounters) values() map[string]string {
+	m := make(map[string]string, len(poolCounterNames))
+	for _, name := range poolCounterNames {
+		m[name] = p.counter(name).String()
+	}
+	return m
+}
+
+// highWater keeps the highest observed value.
+type highWater struct {
+	v int64
+}
+
+func (h *highWater) String() string {
+	return fmt.Sprintf("%d", atomic.LoadInt64(&h.v))
+}
+
+func (h *highWater) update(v int64) {
+	for {
+		current := atomic.LoadInt64(&h.v)
+		if v <= current || atomic.CompareAndSwapInt64(&h.v, current, v) {
+			return
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package metric
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prometheusContentType is the content type of Prometheus text
+// exposition format.
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type (
+	// Snapshotter is implemented by recorders that can report current
+	// values of component instances.
+	Snapshotter interface {
+		Snapshot() []Snapshot
+	}
+
+	// Snapshot holds values of component instance counters.
+	Snapshot struct {
+		ID            string
+		Line          string
+		Type          string
+		Messages      int64
+		Samples       int64
+		Duration      time.Duration // duration of processed signal
+		Latency       time.Duration
+		Queue         time.Duration
+		Processing    time.Duration // total time spent inside component calls
+		ProcessingP50 time.Duration
+		ProcessingP95 time.Duration
+		ProcessingP99 time.Duration
+		ProcessingMax time.Duration
+		Levels        []Level
+	}
+)
+
+// Handler returns http.Handler that serves metrics of recorder in
+// Prometheus text format. Each component instance is labeled with its
+// type, id and line. Durations are exposed in seconds. Use Default to
+// serve metrics of pipes without own recorder.
+func Handler(r Snapshotter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		w.Write(prometheus(r.Snapshot()))
+	})
+}
+
+// family is a Prometheus metric family of component counters.
+type family struct {
+	name  string
+	help  string
+	kind  string
+	value func(Snapshot) string
+}
+
+// families are exposed in this order.
+var families = []family{
+	{
+		name:  "pipe_component_messages_total",
+		help:  "Number of buffers processed by component.",
+		kind:  "counter",
+		value: func(s Snapshot) string { return strconv.FormatInt(s.Messages, 10) },
+	},
+	{
+		name:  "pipe_component_samples_total",
+		help:  "Number of samples processed by component.",
+		kind:  "counter",
+		value: func(s Snapshot) string { return strconv.FormatInt(s.Samples, 10) },
+	},
+	{
+		name:  "pipe_component_signal_seconds_total",
+		help:  "Duration of signal processed by component.",
+		kind:  "counter",
+		value: func(s Snapshot) string { return seconds(s.Duration) },
+	},
+	{
+		name:  "pipe_component_latency_seconds",
+		help:  "Time between the last two calls of component.",
+		kind:  "gauge",
+		value: func(s Snapshot) string { return seconds(s.Latency) },
+	},
+	{
+		name:  "pipe_component_queue_seconds",
+		help:  "Duration of signal that can be queued after component.",
+		kind:  "gauge",
+		value: func(s Snapshot) string { return seconds(s.Queue) },
+	},
+	{
+		name:  "pipe_component_processing_max_seconds",
+		help:  "Longest time spent inside component call.",
+		kind:  "gauge",
+		value: func(s Snapshot) string { return seconds(s.ProcessingMax) },
+	},
+	{
+		name: "pipe_component_real_time_factor",
+		help: "Processing time of component divided by duration of processed signal.",
+		kind: "gauge",
+		value: func(s Snapshot) string {
+			return strconv.FormatFloat(realTimeFactor(s.Processing, s.Duration), 'g', -1, 64)
+		},
+	},
+}
+
+// levelFamily is a Prometheus metric family of channel levels.
+type levelFamily struct {
+	name  string
+	help  string
+	kind  string
+	value func(Level) float64
+}
+
+// levelFamilies are exposed in this order.
+var levelFamilies = []levelFamily{
+	{
+		name:  "pipe_component_peak",
+		help:  "Peak level of component output channel relative to full scale.",
+		kind:  "gauge",
+		value: func(l Level) float64 { return l.Peak },
+	},
+	{
+		name:  "pipe_component_rms",
+		help:  "RMS level of component output channel relative to full scale.",
+		kind:  "gauge",
+		value: func(l Level) float64 { return l.RMS },
+	},
+	{
+		name:  "pipe_component_dc",
+		help:  "DC offset of component output channel relative to full scale.",
+		kind:  "gauge",
+		value: func(l Level) float64 { return l.DC },
+	},
+	{
+		name:  "pipe_component_clips_total",
+		help:  "Number of samples above full scale in component output channel.",
+		kind:  "counter",
+		value: func(l Level) float64 { return float64(l.Clips) },
+	},
+}
+
+// quantiles of processing time summary.
+var quantiles = []struct {
+	name  string
+	value func(Snapshot) time.Duration
+}{
+	{"0.5", func(s Snapshot) time.Duration { return s.ProcessingP50 }},
+	{"0.95", func(s Snapshot) time.Duration { return s.ProcessingP95 }},
+	{"0.99", func(s Snapshot) time.Duration { return s.ProcessingP99 }},
+}
+
+// Snapshot implements Snapshotter. Snapshots are sorted by id.
+func (e *Expvar) Snapshot() []Snapshot {
+	e.Lock()
+	defer e.Unlock()
+	snapshots := make([]Snapshot, 0, len(e.m))
+	for id, c := range e.m {
+		snapshots = append(snapshots, Snapshot{
+			ID:            id,
+			Line:          c.line,
+			Type:          c.componentType,
+			Messages:      c.messages.Value(),
+			Samples:       c.samples.Value(),
+			Duration:      c.duration.value(),
+			Latency:       c.latency.value(),
+			Queue:         c.queue.value(),
+			Processing:    c.processing.value(),
+			ProcessingP50: c.calls.quantile(0.5),
+			ProcessingP95: c.calls.quantile(0.95),
+			ProcessingP99: c.calls.quantile(0.99),
+			ProcessingMax: c.calls.maximum(),
+			Levels:        append([]Level(nil), c.levels...),
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID < snapshots[j].ID
+	})
+	return snapshots
+}
+
+// prometheus returns metrics of snapshots in Prometheus text format.
+func prometheus(snapshots []Snapshot) []byte {
+	var b bytes.Buffer
+	for _, f := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		for _, s := range snapshots {
+			fmt.Fprintf(&b, "%s{%s} %s\n", f.name, componentLabels(s), f.value(s))
+		}
+	}
+
+	// time spent inside component calls
+	const processing = "pipe_component_processing_seconds"
+	fmt.Fprintf(&b, "# HELP %s Time spent inside component calls.\n# TYPE %s summary\n", processing, processing)
+	for _, s := range snapshots {
+		labels := componentLabels(s)
+		for _, q := range quantiles {
+			fmt.Fprintf(&b, "%s{%s,quantile=\"%s\"} %s\n", processing, labels, q.name, seconds(q.value(s)))
+		}
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", processing, labels, seconds(s.Processing))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", processing, labels, s.Messages)
+	}
+
+	// signal levels of channels
+	for _, f := range levelFamilies {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		for _, s := range snapshots {
+			labels := componentLabels(s)
+			for i, l := range s.Levels {
+				fmt.Fprintf(&b, "%s{%s,channel=\"%d\"} %s\n", f.name, labels, i, strconv.FormatFloat(f.value(l), 'g', -1, 64))
+			}
+		}
+	}
+
+	// real-time factor of lines, see Expvar.RealTimeFactor
+	lines := make(map[string]*lineTime)
+	for _, s := range snapshots {
+		l, ok := lines[s.Line]
+		if !ok {
+			l = &lineTime{}
+			lines[s.Line] = l
+		}
+		l.processing += s.Processing
+		if s.Duration > l.duration {
+			l.duration = s.Duration
+		}
+	}
+	lineIDs := make([]string, 0, len(lines))
+	for line := range lines {
+		lineIDs = append(lineIDs, line)
+	}
+	sort.Strings(lineIDs)
+	b.WriteString("# HELP pipe_line_real_time_factor Processing time of line components divided by duration of processed signal.\n# TYPE pipe_line_real_time_factor gauge\n")
+	for _, line := range lineIDs {
+		fmt.Fprintf(&b, "pipe_line_real_time_factor{line=\"%s\"} %s\n", escapeLabel(line), strconv.FormatFloat(realTimeFactor(lines[line].processing, lines[line].duration), 'g', -1, 64))
+	}
+
+	// number of instances per type
+	instances := make(map[string]int)
+	for _, s := range snapshots {
+		instances[s.Type]++
+	}
+	types := make([]string, 0, len(instances))
+	for t := range instances {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	b.WriteString("# HELP pipe_components Number of component instances.\n# TYPE pipe_components gauge\n")
+	for _, t := range types {
+		fmt.Fprintf(&b, "pipe_components{type=\"%s\"} %d\n", escapeLabel(t), instances[t])
+	}
+	return b.Bytes()
+}
+
+// lineTime is the processing time and signal duration of line.
+type lineTime struct {
+	processing time.Duration
+	duration   time.Duration
+}
+
+// componentLabels returns labels of component instance.
+func componentLabels(s Snapshot) string {
+	return fmt.Sprintf("type=\"%s\",id=\"%s\",line=\"%s\"", escapeLabel(s.Type), escapeLabel(s.ID), escapeLabel(s.Line))
+}
+
+// labelEscaper escapes label values of Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// seconds formats duration in seconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'g', -1, 64)
+}
@@ -0,0 +1,79 @@
+package metric_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/metric"
+)
+
+func TestHandler(t *testing.T) {
+	recorder := metric.NewExpvar()
+	sampleRate := signal.SampleRate(44100)
+	fn := metric.Meter(recorder, "line1", "gain\n1", &sampleRate, sampleRate)(2)
+	fn(441, time.Millisecond)
+	fn(441, time.Millisecond)
+	metric.Meter(recorder, "line1", "sink\"1", "test", sampleRate)
+	levels := metric.Levels(recorder, "gain\n1", sampleRate, 1)(0)
+	levels.Add(0, -2)
+	levels.Advance(1)
+
+	w := httptest.NewRecorder()
+	metric.Handler(recorder).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(w.Body.String(), "\n")
+	expected := []string{
+		"# TYPE pipe_component_messages_total counter",
+		`pipe_component_messages_total{type="signal.SampleRate",id="gain\n1",line="line1"} 2`,
+		`pipe_component_messages_total{type="string",id="sink\"1",line="line1"} 0`,
+		`pipe_component_samples_total{type="signal.SampleRate",id="gain\n1",line="line1"} 882`,
+		"# TYPE pipe_component_signal_seconds_total counter",
+		`pipe_component_signal_seconds_total{type="signal.SampleRate",id="gain\n1",line="line1"} 0.02`,
+		"# TYPE pipe_component_queue_seconds gauge",
+		`pipe_component_queue_seconds{type="signal.SampleRate",id="gain\n1",line="line1"} 0.02`,
+		"# TYPE pipe_component_processing_seconds summary",
+		`pipe_component_processing_seconds{type="signal.SampleRate",id="gain\n1",line="line1",quantile="0.99"} 0.001`,
+		`pipe_component_processing_seconds_sum{type="signal.SampleRate",id="gain\n1",line="line1"} 0.002`,
+		`pipe_component_processing_seconds_count{type="signal.SampleRate",id="gain\n1",line="line1"} 2`,
+		`pipe_component_real_time_factor{type="signal.SampleRate",id="gain\n1",line="line1"} 0.1`,
+		`pipe_component_peak{type="signal.SampleRate",id="gain\n1",line="line1",channel="0"} 2`,
+		`pipe_component_dc{type="signal.SampleRate",id="gain\n1",line="line1",channel="0"} -2`,
+		"# TYPE pipe_component_clips_total counter",
+		`pipe_component_clips_total{type="signal.SampleRate",id="gain\n1",line="line1",channel="0"} 1`,
+		`pipe_line_real_time_factor{line="line1"} 0.1`,
+		`pipe_components{type="signal.SampleRate"} 1`,
+		`pipe_components{type="string"} 1`,
+	}
+	for _, line := range expected {
+		assert.Contains(t, lines, line)
+	}
+
+	recorder.Drop("line1")
+	w = httptest.NewRecorder()
+	metric.Handler(recorder).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.NotContains(t, w.Body.String(), "line1")
+}
+
+func TestHandlerMemory(t *testing.T) {
+	recorder := metric.NewMemory()
+	sampleRate := signal.SampleRate(44100)
+	fn := metric.Meter(recorder, "line1", "gain1", "test", sampleRate)(2)
+	for i := 0; i < metric.MaxMeasurements+1; i++ {
+		fn(441, time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	metric.Handler(recorder).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	lines := strings.Split(w.Body.String(), "\n")
+	assert.Contains(t, lines, `pipe_component_messages_total{type="string",id="gain1",line="line1"} 1025`)
+	assert.Contains(t, lines, `pipe_component_queue_seconds{type="string",id="gain1",line="line1"} 0.02`)
+}
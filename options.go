@@ -0,0 +1,179 @@
+package pipe
+
+import (
+	"time"
+
+	"pipelined.dev/pipe/internal/pool"
+	"pipelined.dev/pipe/internal/runner"
+)
+
+var (
+	// ErrMutation is returned in debug mode when sink changes the content
+	// of buffer that is shared with other sinks.
+	ErrMutation = runner.ErrMutation
+	// ErrLeak is returned in debug mode when buffers are not released by
+	// the end of the run.
+	ErrLeak = pool.ErrLeak
+	// ErrUseAfterFree is returned in debug mode when buffer is modified
+	// after it was released.
+	ErrUseAfterFree = pool.ErrUseAfterFree
+	// ErrDoubleFree is returned in debug mode when buffer is released
+	// twice.
+	ErrDoubleFree = pool.ErrDoubleFree
+)
+
+// RunOption configures a single run of the pipe.
+type RunOption func(*runConfig)
+
+// runConfig holds the options of a single run.
+type runConfig struct {
+	debug     bool
+	poolLimit int
+	workers   int // number of goroutines of channel processors
+	depth     int
+	depths    map[interface{}]int // depth overrides of components
+	transport Transport
+	levels    time.Duration // interval of level metering
+	report    func(Report)
+	limit     time.Duration // duration of signal pumped by each line
+}
+
+// Transport is the kind of queues between stages of lines.
+type Transport int
+
+const (
+	// ChannelTransport connects stages with buffered channels. It's the
+	// default transport.
+	ChannelTransport Transport = iota
+	// RingTransport connects stages with preallocated lock-free ring
+	// buffers. Stages exchange buffers without locks and yield the
+	// processor for a while before they block, which reduces the
+	// overhead of hand-overs when buffers are small.
+	RingTransport
+)
+
+// WithDebug enables the debug mode of the run. Buffers that are shared
+// by sinks are checksummed before and after each sink call and the run
+// fails with ErrMutation if any sink changes them. Every buffer is
+// tracked with its allocating line: released buffers are poisoned to
+// detect use after free and buffers that are still outstanding when the
+// run is done are reported with ErrLeak. Leaks are not reported if the
+// run is cancelled.
+func WithDebug() RunOption {
+	return func(c *runConfig) {
+		c.debug = true
+	}
+}
+
+// WithPoolLimit limits the number of buffers that each line of the pipe
+// can have in use at the same time. Pump is blocked when the limit is
+// reached until downstream components release buffers. Buffers are not
+// limited by default. Each stage holds up to its queue depth of buffers
+// plus the one it processes, limits below that reduce the effective
+// queue depth.
+func WithPoolLimit(max int) RunOption {
+	return func(c *runConfig) {
+		c.poolLimit = max
+	}
+}
+
+// WithChannelWorkers sets the number of goroutines that process
+// channels of ChannelProcessor components. Goroutines are shared by all
+// channel processors of the run. GOMAXPROCS goroutines are used by
+// default. Scheduled pipes use goroutines of their scheduler instead.
+func WithChannelWorkers(n int) RunOption {
+	return func(c *runConfig) {
+		c.workers = n
+	}
+}
+
+// WithQueueDepth sets the number of buffers that can be queued between
+// stages of lines. Deeper queues let pumps prefetch buffers and absorb
+// jitter, but increase latency and the number of buffers in flight.
+// Zero depth makes stages hand over buffers directly. Default depth is
+// 1.
+func WithQueueDepth(depth int) RunOption {
+	return func(c *runConfig) {
+		c.depth = depth
+	}
+}
+
+// WithComponentQueueDepth overrides the queue depth for provided
+// component. For pumps and processors it is the depth of their output,
+// for sinks it is the depth of their input.
+func WithComponentQueueDepth(component interface{}, depth int) RunOption {
+	return func(c *runConfig) {
+		if c.depths == nil {
+			c.depths = make(map[interface{}]int)
+		}
+		c.depths[component] = depth
+	}
+}
+
+// WithTransport sets the kind of queues between stages of lines. Queue
+// depths are applied to any transport, though ring holds at least one
+// buffer.
+func WithTransport(t Transport) RunOption {
+	return func(c *runConfig) {
+		c.transport = t
+	}
+}
+
+// WithLevels enables metering of signal levels at the output of pumps
+// and processors. Peak, RMS and DC offset of each channel are measured
+// over provided interval of signal and passed to the recorder of pipe
+// if it implements metric.LevelRecorder. Samples above full scale are
+// counted as clips. Levels are not metered by default.
+func WithLevels(interval time.Duration) RunOption {
+	return func(c *runConfig) {
+		c.levels = interval
+	}
+}
+
+// WithLimit limits the duration of signal that each line pumps in the
+// run. Line is done once its pump reaches the limit, the last buffer is
+// trimmed to it. Report of limited run has StopLimit reason. Runs are
+// not limited by default.
+func WithLimit(d time.Duration) RunOption {
+	return func(c *runConfig) {
+		c.limit = d
+	}
+}
+
+// WithReport makes a report of the run. Provided function is called
+// with the report once all components are done, before the feedback
+// channel of the run is closed. Note that Wait returns on the first
+// error, before the report is made. Stepper calls it on the step that
+// finishes the run or on Close.
+func WithReport(fn func(Report)) RunOption {
+	return func(c *runConfig) {
+		c.report = fn
+	}
+}
+
+// newRunConfig applies options to the default run configuration.
+func newRunConfig(options []RunOption) runConfig {
+	c := runConfig{
+		depth: 1,
+	}
+	for _, option := range options {
+		option(&c)
+	}
+	return c
+}
+
+// depthOf returns the queue depth of the component runner with
+// provided id.
+func (c runConfig) depthOf(ch chain, id string) int {
+	for component, depth := range c.depths {
+		if ch.components[component] == id {
+			return depth
+		}
+	}
+	return c.depth
+}
+
+// ring returns true if stages are connected with rings.
+func (c runConfig) ring() bool {
+	return c.transport == RingTransport
+}
@@ -3,6 +3,7 @@ package pipe
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"pipelined.dev/signal"
 
@@ -18,8 +19,18 @@ import (
 type Pipe struct {
 	h                *state.Handle
 	lines            map[*Line]string  // map pipe to chain id
-	chains           map[string]chain  // map chain id to chain
+	chains           map[string]*chain // map chain id to chain
 	chainByComponent map[string]string // map component id to chain id
+	recorder         metric.Recorder
+	cancel           <-chan struct{} // cancellation of the current run
+	executor
+}
+
+// executor defines how lines of the pipe are executed.
+type executor struct {
+	fused   bool            // run each line in a single goroutine
+	group   *runner.Group   // run fused lines with scheduler workers
+	workers *runner.Workers // workers of channel processors, nil if they're started per run
 }
 
 // chain is a runtime chain of the pipeline.
@@ -37,20 +48,77 @@ type chain struct {
 
 // New creates a new pipeline.
 // Returned pipeline is in Ready state.
+// Each component of the pipeline runs in its own goroutine.
 func New(ls ...*Line) (*Pipe, error) {
+	return Config{}.New(ls...)
+}
+
+// NewFused creates a new pipeline that executes each line in a single
+// goroutine. Components of the line are called in order for every
+// buffer, which avoids context switches between them and suits small
+// buffer sizes. Queue depth options have no effect on fused lines.
+// Returned pipeline is in Ready state.
+func NewFused(ls ...*Line) (*Pipe, error) {
+	return Config{}.NewFused(ls...)
+}
+
+// NewScheduled creates a new pipeline attached to the scheduler. Lines
+// are fused and executed by the workers of scheduler instead of their
+// own goroutines. Pipes with higher priority process more buffers per
+// turn. Returned pipeline is in Ready state.
+func NewScheduled(s *Scheduler, priority int, ls ...*Line) (*Pipe, error) {
+	return Config{}.NewScheduled(s, priority, ls...)
+}
+
+// Config holds the options of pipes. Zero value is the default
+// configuration.
+type Config struct {
+	// Recorder receives metrics of pipe components. Metrics are
+	// recorded with metric.Default if it's nil.
+	Recorder metric.Recorder
+}
+
+// New creates a new pipeline with the config. See New for details.
+func (config Config) New(ls ...*Line) (*Pipe, error) {
+	return newPipe(config, executor{}, ls)
+}
+
+// NewFused creates a new fused pipeline with the config. See NewFused
+// for details.
+func (config Config) NewFused(ls ...*Line) (*Pipe, error) {
+	return newPipe(config, executor{fused: true}, ls)
+}
+
+// NewScheduled creates a new scheduled pipeline with the config. See
+// NewScheduled for details.
+func (config Config) NewScheduled(s *Scheduler, priority int, ls ...*Line) (*Pipe, error) {
+	return newPipe(config, executor{fused: true, group: runner.NewGroup(s.s, priority), workers: s.workers}, ls)
+}
+
+// recorder returns the recorder of config.
+func (config Config) recorder() metric.Recorder {
+	if config.Recorder == nil {
+		return metric.Default
+	}
+	return config.Recorder
+}
+
+func newPipe(config Config, e executor, ls []*Line) (*Pipe, error) {
+	recorder := config.recorder()
 	lines := make(map[*Line]string)
-	chains := make(map[string]chain)
+	chains := make(map[string]*chain)
 	chainByComponent := make(map[string]string)
 	for _, p := range ls {
 		// bind all lines
-		c, err := bindLine(p)
+		c, err := bindLine(p, recorder)
 		if err != nil {
+			dropMetrics(recorder, chains)
 			return nil, fmt.Errorf("error binding line: %w", err)
 		}
 		// map pipe to chain id
 		lines[p] = c.uid
 		// map chains
-		chains[c.uid] = c
+		chains[c.uid] = &c
 		for _, componentID := range c.components {
 			chainByComponent[componentID] = c.uid
 		}
@@ -60,41 +128,61 @@ func New(ls ...*Line) (*Pipe, error) {
 		lines:            lines,
 		chains:           chains,
 		chainByComponent: chainByComponent,
+		recorder:         recorder,
+		executor:         e,
 	}
-	p.h = state.NewHandle(start(p), newMessage(p), pushParams(p))
+	p.h = state.NewHandle(newMessage(p), pushParams(p), pause(p))
 	go state.Loop(p.h)
 	return p, nil
 }
 
-func bindLine(p *Line) (chain, error) {
+func bindLine(p *Line, recorder metric.Recorder) (c chain, err error) {
 	components := make(map[interface{}]string)
 	pipeID := newUID()
+	// drop metrics of components that were bound
+	defer func() {
+		if err != nil {
+			recorder.Drop(pipeID)
+		}
+	}()
 	// bind pump
-	pumpFn, sampleRate, numChannels, err := p.Pump.Pump(pipeID)
+	pumpRunner, sampleRate, numChannels, err := bindPump(pipeID, p.Pump)
 	if err != nil {
 		return chain{}, fmt.Errorf("pump: %w", err)
 	}
-	pumpRunner := runner.Pump{
-		ID:    newUID(),
-		Fn:    runner.PumpFunc(pumpFn),
-		Meter: metric.Meter(p.Pump, signal.SampleRate(sampleRate)),
-		Hooks: BindHooks(p.Pump),
-	}
+	pumpRunner.Meter = metric.Meter(recorder, pipeID, pumpRunner.ID, p.Pump, sampleRate)
+	pumpRunner.Levels = metric.Levels(recorder, pumpRunner.ID, sampleRate, numChannels)
 	components[p.Pump] = pumpRunner.ID
+	layout, err := outputLayout(pipeID, p.Pump, numChannels)
+	if err != nil {
+		return chain{}, fmt.Errorf("pump: %w", err)
+	}
 
 	// bind processors
+	inputSampleRate, inputNumChannels := sampleRate, numChannels
 	processorRunners := make([]runner.Processor, 0, len(p.Processors))
 	for _, proc := range p.Processors {
-		processFn, err := proc.Process(pipeID, sampleRate, numChannels)
-		if err != nil {
+		if err := receiveLayout(pipeID, proc, layout); err != nil {
 			return chain{}, fmt.Errorf("processor: %w", err)
 		}
-		processorRunner := runner.Processor{
-			ID:    newUID(),
-			Fn:    runner.ProcessFunc(processFn),
-			Meter: metric.Meter(proc, signal.SampleRate(sampleRate)),
-			Hooks: BindHooks(proc),
+		var processorRunner runner.Processor
+		switch v := proc.(type) {
+		case Resampler:
+			processorRunner, sampleRate, err = bindResampler(pipeID, v, sampleRate, numChannels)
+		case Remixer:
+			processorRunner, numChannels, err = bindRemixer(pipeID, v, sampleRate, numChannels)
+			if err == nil {
+				layout, err = outputLayout(pipeID, v, numChannels)
+			}
+		default:
+			processorRunner, err = bindProcessor(pipeID, proc, sampleRate, numChannels)
 		}
+		if err != nil {
+			return chain{}, fmt.Errorf("processor: %w", err)
+		}
+		// resamplers are measured with output sample rate
+		processorRunner.Meter = metric.Meter(recorder, pipeID, processorRunner.ID, proc, sampleRate)
+		processorRunner.Levels = metric.Levels(recorder, processorRunner.ID, sampleRate, numChannels)
 		processorRunners = append(processorRunners, processorRunner)
 		components[proc] = processorRunner.ID
 	}
@@ -102,32 +190,80 @@ func bindLine(p *Line) (chain, error) {
 	// bind sinks
 	sinkRunners := make([]runner.Sink, 0, len(p.Sinks))
 	for _, sink := range p.Sinks {
-		sinkFn, err := sink.Sink(pipeID, sampleRate, numChannels)
-		if err != nil {
+		if err := receiveLayout(pipeID, sink, layout); err != nil {
 			return chain{}, fmt.Errorf("sink: %w", err)
 		}
-		sinkRunner := runner.Sink{
-			ID:    newUID(),
-			Fn:    runner.SinkFunc(sinkFn),
-			Meter: metric.Meter(sink, signal.SampleRate(sampleRate)),
-			Hooks: BindHooks(sink),
+		sinkRunner, err := bindSink(pipeID, sink, sampleRate, numChannels)
+		if err != nil {
+			return chain{}, fmt.Errorf("sink: %w", err)
 		}
+		sinkRunner.Meter = metric.Meter(recorder, pipeID, sinkRunner.ID, sink, sampleRate)
 		sinkRunners = append(sinkRunners, sinkRunner)
 		components[sink] = sinkRunner.ID
 	}
 	return chain{
 		uid:         pipeID,
-		sampleRate:  sampleRate,
-		numChannels: numChannels,
+		sampleRate:  inputSampleRate,
+		numChannels: inputNumChannels,
 		pump:        pumpRunner,
 		processors:  processorRunners,
 		sinks:       sinkRunners,
 		take:        make(chan runner.Message),
 		components:  components,
-		params:      make(map[string][]func()),
 	}, nil
 }
 
+// bindResampler binds resampler and returns its output sample rate.
+func bindResampler(pipeID string, r Resampler, sampleRate signal.SampleRate, numChannels int) (runner.Processor, signal.SampleRate, error) {
+	resampleFn, outputSampleRate, err := r.Resample(pipeID, sampleRate, numChannels)
+	if err != nil {
+		return runner.Processor{}, 0, err
+	}
+	if sampleRate == 0 || outputSampleRate == 0 {
+		return runner.Processor{}, 0, fmt.Errorf("invalid resampling from %d to %d", sampleRate, outputSampleRate)
+	}
+	skipSilence, tail := silenceTail(pipeID, r)
+	return runner.Processor{
+		ID:          newUID(),
+		Convert:     runner.ConvertFunc(resampleFn),
+		OutputSize:  resampledSize(sampleRate, outputSampleRate),
+		NumChannels: numChannels,
+		SkipSilence: skipSilence,
+		Tail:        tail,
+		Hooks:       BindHooks(r),
+	}, outputSampleRate, nil
+}
+
+// bindRemixer binds remixer and returns its output number of channels.
+func bindRemixer(pipeID string, r Remixer, sampleRate signal.SampleRate, numChannels int) (runner.Processor, int, error) {
+	remixFn, outputNumChannels, err := r.Remix(pipeID, sampleRate, numChannels)
+	if err != nil {
+		return runner.Processor{}, 0, err
+	}
+	if outputNumChannels <= 0 {
+		return runner.Processor{}, 0, fmt.Errorf("invalid number of output channels: %d", outputNumChannels)
+	}
+	skipSilence, tail := silenceTail(pipeID, r)
+	return runner.Processor{
+		ID:          newUID(),
+		Convert:     runner.ConvertFunc(remixFn),
+		NumChannels: outputNumChannels,
+		SkipSilence: skipSilence,
+		Tail:        tail,
+		Hooks:       BindHooks(r),
+	}, outputNumChannels, nil
+}
+
+// resampledSize returns a function to calculate the number of samples
+// after resampling. The result is rounded up. Since it's applied to the
+// totals of the run, the output buffer is at most the rounded up size of
+// input buffer.
+func resampledSize(in, out signal.SampleRate) func(int64) int64 {
+	return func(size int64) int64 {
+		return (size*int64(out) + int64(in) - 1) / int64(in)
+	}
+}
+
 // ComponentID finds id of the component within network.
 func (p *Pipe) ComponentID(component interface{}) (id string, ok bool) {
 	for _, c := range p.chains {
@@ -139,58 +275,210 @@ func (p *Pipe) ComponentID(component interface{}) (id string, ok bool) {
 }
 
 // start starts the execution of pipe.
-func start(p *Pipe) state.StartFunc {
-	return func(bufferSize int, cancel <-chan struct{}, give chan<- string) []<-chan error {
+func start(p *Pipe, bufferSize int, config runConfig) state.StartFunc {
+	return func(cancel <-chan struct{}, give chan<- string) []<-chan error {
+		p.cancel = cancel
 		// error channel for each component
 		errcList := make([]<-chan error, 0)
+		var tracker *pool.Tracker
+		if config.debug {
+			tracker = pool.NewTracker()
+		}
+		var report *runReport
+		if config.report != nil {
+			report = newRunReport()
+		}
+		if p.group != nil {
+			errcList = append(errcList, p.group.Start(cancel, give, len(p.chains)))
+		}
+		workers := p.workers
+		if workers == nil {
+			workers = runner.NewWorkers(config.workers)
+		}
 		for _, c := range p.chains {
-			p := pool.New(c.numChannels, bufferSize)
+			r, pools := c.runners(bufferSize, p.recorder, config, tracker, workers)
+			if report != nil {
+				r = report.track(*c, r)
+			}
+			if p.fused {
+				if p.group != nil {
+					r.Schedule(p.group, pools, c.uid, c.take)
+				} else {
+					errcList = append(errcList, r.Run(pools, c.uid, cancel, give, c.take))
+				}
+				continue
+			}
+
 			// start pump
-			out, errs := c.pump.Run(p, c.uid, c.pump.ID, cancel, give, c.take)
+			out, errs := r.Pump.Run(pools[0], c.uid, r.Pump.ID, cancel, give, c.take)
 			errcList = append(errcList, errs)
 
 			// start chained processesing
-			for _, proc := range c.processors {
-				out, errs = proc.Run(c.uid, proc.ID, cancel, out)
+			for i, proc := range r.Processors {
+				out, errs = proc.Run(pools[i], pools[i+1], c.uid, proc.ID, cancel, out)
 				errcList = append(errcList, errs)
 			}
 
-			sinkErrcList := runner.Broadcast(p, c.uid, c.sinks, cancel, out)
+			sinkErrcList := runner.Broadcast(pools[len(pools)-1], c.uid, r.Sinks, cancel, out)
 			errcList = append(errcList, sinkErrcList...)
 		}
+		if tracker != nil {
+			errcList = trackBuffers(tracker, cancel, errcList)
+		}
+		if report != nil {
+			errcList = report.collect(cancel, config.report, errcList)
+		}
 		return errcList
 	}
 }
 
+// runners returns runners of chain configured for the run. Returned
+// pools contain the pool of pump followed by the output pool of each
+// processor. Buffers of pools are counted by recorder if it implements
+// metric.PoolRecorder. Channel processors share provided workers.
+func (c chain) runners(bufferSize int, recorder metric.Recorder, config runConfig, tracker *pool.Tracker, workers *runner.Workers) (runner.Fused, []runner.Pool) {
+	var limit *pool.Limit
+	if config.poolLimit > 0 {
+		limit = pool.NewLimit(config.poolLimit)
+	}
+	counter, _ := recorder.(metric.PoolRecorder)
+	// newPool returns a pool of chain for provided shape
+	newPool := func(numChannels, size int) pool.Pool {
+		p := pool.Get(numChannels, size).WithLimit(limit).WithRecorder(counter)
+		if tracker != nil {
+			p = p.WithTracker(tracker, c.uid)
+		}
+		return p
+	}
+	pump := c.pump
+	pump.Depth = config.depthOf(c, pump.ID)
+	pump.Ring = config.ring()
+	pump.LevelInterval = config.levels
+	if config.limit > 0 {
+		pump.Limit = int64(c.sampleRate.SamplesIn(config.limit))
+	}
+	pools := make([]runner.Pool, 0, len(c.processors)+1)
+	pools = append(pools, newPool(c.numChannels, bufferSize))
+	processors := make([]runner.Processor, len(c.processors))
+	size := bufferSize
+	for i, proc := range c.processors {
+		procPool := pools[i]
+		// converting processors need a pool of output shape
+		if proc.Convert != nil {
+			if proc.OutputSize != nil {
+				size = int(proc.OutputSize(int64(size)))
+			}
+			procPool = newPool(proc.NumChannels, size)
+		}
+		proc.Depth = config.depthOf(c, proc.ID)
+		proc.Ring = config.ring()
+		proc.LevelInterval = config.levels
+		proc.Workers = workers
+		processors[i] = proc
+		pools = append(pools, procPool)
+	}
+	sinks := make([]runner.Sink, len(c.sinks))
+	for i := range c.sinks {
+		sinks[i] = c.sinks[i]
+		sinks[i].Depth = config.depthOf(c, sinks[i].ID)
+		sinks[i].Ring = config.ring()
+		sinks[i].Debug = config.debug
+	}
+	return runner.Fused{
+		Pump:       pump,
+		Processors: processors,
+		Sinks:      sinks,
+	}, pools
+}
+
+// trackBuffers checks the tracker once all components are done. Only
+// the first error of each component is passed further. Leaks are not
+// reported if the run was cancelled.
+func trackBuffers(t *pool.Tracker, cancel <-chan struct{}, errcList []<-chan error) []<-chan error {
+	var wg sync.WaitGroup
+	tracked := make([]<-chan error, 0, len(errcList)+1)
+	for _, errc := range errcList {
+		out := make(chan error, 1)
+		wg.Add(1)
+		go func(in <-chan error) {
+			defer wg.Done()
+			defer close(out)
+			for err := range in {
+				select {
+				case out <- err:
+				default:
+				}
+			}
+		}(errc)
+		tracked = append(tracked, out)
+	}
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		wg.Wait()
+		if err := t.Err(); err != nil {
+			errs <- err
+			return
+		}
+		select {
+		case <-cancel:
+		default:
+			if err := t.Leaks(); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	return append(tracked, errs)
+}
+
 // newMessage creates a new message with cached Params.
 // if new Params are pushed into pipe - next message will contain them.
 func newMessage(p *Pipe) state.NewMessageFunc {
 	return func(pipeID string) {
 		c := p.chains[pipeID]
-		m := runner.Message{PipeID: c.uid}
-		if len(c.params) > 0 {
-			m.Params = c.params
-			c.params = make(map[string][]func())
+		m := runner.Message{PipeID: c.uid, Params: c.params}
+		// pump might be cancelled after its request was accepted, params
+		// are kept for the next run then
+		select {
+		case c.take <- m:
+			// pushed params are handed over to the message, the next
+			// push starts a new set
+			c.params = nil
+		case <-p.cancel:
+		}
+	}
+}
+
+// pause parks scheduled lines while pipe is paused. Nil is returned if
+// pipe is not scheduled.
+func pause(p *Pipe) state.PauseFunc {
+	if p.group == nil {
+		return nil
+	}
+	return func(paused bool) {
+		if paused {
+			p.group.Pause()
+		} else {
+			p.group.Resume()
 		}
-		c.take <- m
 	}
 }
 
 func pushParams(p *Pipe) state.PushParamsFunc {
 	return func(params state.Params) {
 		for id, param := range params {
-			chainID := p.chainByComponent[id]
-			chain := p.chains[chainID]
-			chain.params = chain.params.Append(map[string][]func(){id: param})
+			if chain, ok := p.chains[p.chainByComponent[id]]; ok {
+				chain.params = chain.params.Add(id, param...)
+			}
 		}
 	}
 }
 
-// Run sends a run event into handle.
+// Run sends a run event into handle. Options are applied to this run only.
 // Calling this method after handle is closed causes a panic.
 // Feedback channel is closed when Ready state is reached or context is cancelled.
-func (p *Pipe) Run(ctx context.Context, bufferSize int) chan error {
-	return p.h.Run(ctx, bufferSize)
+func (p *Pipe) Run(ctx context.Context, bufferSize int, options ...RunOption) chan error {
+	return p.h.Run(ctx, start(p, bufferSize, newRunConfig(options)))
 }
 
 // Pause sends a pause event into handle.
@@ -207,10 +495,28 @@ func (p *Pipe) Resume() chan error {
 	return p.h.Resume()
 }
 
-// Close must be called to clean up handle's resources.
+// Close must be called to clean up handle's resources. Metrics of
+// pipe components are dropped once the run is interrupted, so they
+// include the last buffers.
 // Feedback is closed when line is done.
 func (p *Pipe) Close() chan error {
-	return p.h.Interrupt()
+	interrupted := p.h.Interrupt()
+	feedback := make(chan error, 1)
+	go func() {
+		defer close(feedback)
+		for err := range interrupted {
+			feedback <- err
+		}
+		dropMetrics(p.recorder, p.chains)
+	}()
+	return feedback
+}
+
+// dropMetrics drops metrics of all components of chains.
+func dropMetrics(r metric.Recorder, chains map[string]*chain) {
+	for id := range chains {
+		r.Drop(id)
+	}
 }
 
 // Push new params into pipe.
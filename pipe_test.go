@@ -2,13 +2,22 @@ package pipe_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/goleak"
 
+	"pipelined.dev/signal"
+
 	"pipelined.dev/pipe"
 	"pipelined.dev/pipe/internal/mock"
+	"pipelined.dev/pipe/internal/state"
+	"pipelined.dev/pipe/meta"
+	"pipelined.dev/pipe/metric"
 )
 
 const (
@@ -47,6 +56,7 @@ func TestPipe(t *testing.T) {
 	pumpID, ok := l.ComponentID(pump)
 	assert.True(t, ok)
 	assert.NotEmpty(t, pumpID)
+	assert.NotContains(t, pumpID, "\n")
 	// push new limit for pump
 	newLimit := 200
 	paramFn := pump.LimitParam(newLimit)
@@ -66,9 +76,249 @@ func TestPipe(t *testing.T) {
 	pipe.Wait(l.Close())
 }
 
+// counter is a mock component that counts processed samples.
+type counter interface {
+	Count() (messages, samples int)
+}
+
+// lineTest is a run of a single line until the end.
+type lineTest struct {
+	name    string
+	newPipe func(...*pipe.Line) (*pipe.Pipe, error) // pipe.New if nil
+	line    *pipe.Line
+	options []pipe.RunOption
+	err     error // expected error of the run
+	// counted components must process samples number of samples.
+	counted []counter
+	samples int
+	start   func(*testing.T, *pipe.Pipe) // called before the run
+	running func(*testing.T, *pipe.Pipe) // called before pipe is closed
+	check   func(*testing.T)             // called after pipe is closed
+}
+
+// runLineTests creates a pipe for each test, runs it until the end and
+// closes it.
+func runLineTests(t *testing.T, tests []lineTest) {
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			newPipe := test.newPipe
+			if newPipe == nil {
+				newPipe = pipe.New
+			}
+			l, err := newPipe(test.line)
+			if err != nil {
+				t.Fatalf("error creating pipe: %v", err)
+			}
+			if test.start != nil {
+				test.start(t, l)
+			}
+			err = pipe.Wait(l.Run(context.Background(), bufferSize, test.options...))
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err))
+			} else {
+				assert.Nil(t, err)
+			}
+			if test.running != nil {
+				test.running(t, l)
+			}
+			pipe.Wait(l.Close())
+
+			for _, c := range test.counted {
+				_, samples := c.Count()
+				assert.Equal(t, test.samples, samples)
+			}
+			if test.check != nil {
+				test.check(t)
+			}
+		})
+	}
+}
+
+func TestResampling(t *testing.T) {
+	pump := &mock.Pump{
+		Limit:       10*bufferSize + 10,
+		NumChannels: 2,
+		SampleRate:  22050,
+	}
+	resampler := &mock.Resampler{
+		OutputSampleRate: 44100,
+	}
+	proc := &mock.Processor{}
+	sink1 := &mock.Sink{}
+	sink2 := &mock.Sink{}
+	runLineTests(t, []lineTest{
+		{
+			name: "upsampling",
+			line: &pipe.Line{
+				Pump:       pump,
+				Processors: pipe.Processors(resampler, proc),
+				Sinks:      pipe.Sinks(sink1),
+			},
+			counted: []counter{proc, sink1},
+			samples: 2 * pump.Limit,
+		},
+		{
+			// rounding of buffer sizes doesn't accumulate
+			name: "rounding",
+			line: &pipe.Line{
+				Pump: &mock.Pump{
+					Limit:       10*bufferSize + 10,
+					NumChannels: 2,
+					SampleRate:  44100,
+				},
+				Processors: pipe.Processors(&mock.Resampler{OutputSampleRate: 48000}),
+				Sinks:      pipe.Sinks(sink2),
+			},
+			counted: []counter{sink2},
+			// 5130 * 48000 / 44100 = 5583.67
+			samples: 5584,
+		},
+	})
+
+	// resampler requires a sample rate
+	_, err := pipe.New(
+		&pipe.Line{
+			Pump:       &mock.Pump{NumChannels: 1},
+			Processors: pipe.Processors(resampler),
+			Sinks:      pipe.Sinks(&mock.Sink{}),
+		},
+	)
+	assert.Error(t, err)
+}
+
+func TestRemixing(t *testing.T) {
+	pump := &mock.Pump{
+		Limit:       10*bufferSize + 10,
+		NumChannels: 1,
+		SampleRate:  44100,
+		Value:       0.5,
+	}
+	remixer := &mock.Remixer{
+		OutputNumChannels: 2,
+	}
+	resampler := &mock.Resampler{
+		OutputSampleRate: 22050,
+	}
+	sink := &mock.Sink{}
+	runLineTests(t, []lineTest{
+		{
+			line: &pipe.Line{
+				Pump:       pump,
+				Processors: pipe.Processors(remixer, resampler),
+				Sinks:      pipe.Sinks(sink),
+			},
+			check: func(t *testing.T) {
+				result := sink.Buffer()
+				assert.Equal(t, 2, result.NumChannels())
+				assert.Equal(t, pump.Limit/2, result.Size())
+				for i := range result {
+					for _, v := range result[i] {
+						assert.Equal(t, pump.Value, v)
+					}
+				}
+			},
+		},
+	})
+
+	// remixer must have output channels
+	_, err := pipe.New(
+		&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(&mock.Remixer{}),
+			Sinks:      pipe.Sinks(sink),
+		},
+	)
+	assert.Error(t, err)
+}
+
+func TestMetadata(t *testing.T) {
+	sink := &mock.Sink{}
+	runLineTests(t, []lineTest{
+		{
+			line: &pipe.Line{
+				Pump: &mock.Pump{
+					Limit:       3 * bufferSize,
+					NumChannels: 1,
+					SampleRate:  22050,
+					Tags: meta.Tags{
+						"source": "test",
+					},
+				},
+				Processors: pipe.Processors(&mock.Resampler{OutputSampleRate: 44100}),
+				Sinks:      pipe.Sinks(sink),
+			},
+			check: func(t *testing.T) {
+				metadata := sink.Meta()
+				assert.Equal(t, 3, len(metadata))
+				for i, d := range metadata {
+					assert.Equal(t, int64(i*2*bufferSize), d.Position)
+					assert.False(t, d.Timestamp.IsZero())
+					if i > 0 {
+						assert.False(t, d.Timestamp.Before(metadata[i-1].Timestamp))
+					}
+					v, ok := d.Tag("source")
+					assert.True(t, ok)
+					assert.Equal(t, "test", v)
+				}
+			},
+		},
+	})
+}
+
+func TestEvents(t *testing.T) {
+	pump := &mock.Pump{
+		Limit:       3 * bufferSize,
+		NumChannels: 1,
+		SampleRate:  22050,
+		Events: []meta.Event{
+			{Offset: 10, Value: meta.Marker{Label: "cue"}},
+		},
+	}
+	proc := &mock.Processor{
+		Events: []meta.Event{
+			{Offset: 5, Value: meta.Onset{Strength: 1}},
+		},
+	}
+	sink1 := &mock.Sink{}
+	sink2 := &mock.Sink{}
+	runLineTests(t, []lineTest{
+		{
+			line: &pipe.Line{
+				Pump:       pump,
+				Processors: pipe.Processors(&mock.Resampler{OutputSampleRate: 44100}, proc),
+				Sinks:      pipe.Sinks(sink1, sink2),
+			},
+			check: func(t *testing.T) {
+				expected := []meta.Event{
+					{Offset: 5, Value: meta.Onset{Strength: 1}},
+					{Offset: 20, Value: meta.Marker{Label: "cue"}},
+				}
+				for _, sink := range []*mock.Sink{sink1, sink2} {
+					metadata := sink.Meta()
+					assert.Equal(t, 3, len(metadata))
+					for _, d := range metadata {
+						assert.Equal(t, expected, d.Events)
+					}
+				}
+			},
+		},
+	})
+}
+
 // This benchmark runs next line:
 // 1 Pump, 2 Processors, 2 Sinks, 1000 buffers of 512 samples with 2 channels.
 func BenchmarkSingleLine(b *testing.B) {
+	benchmarkSingleLine(b)
+}
+
+// This benchmark runs the same line as BenchmarkSingleLine, but stages
+// are connected with rings.
+func BenchmarkSingleLineRing(b *testing.B) {
+	benchmarkSingleLine(b, pipe.WithTransport(pipe.RingTransport))
+}
+
+func benchmarkSingleLine(b *testing.B, options ...pipe.RunOption) {
 	for i := 0; i < b.N; i++ {
 		pump := &mock.Pump{
 			Limit:       862 * bufferSize,
@@ -90,7 +340,939 @@ func BenchmarkSingleLine(b *testing.B) {
 				Sinks:      pipe.Sinks(sink1, sink2),
 			},
 		)
-		pipe.Wait(l.Run(context.Background(), bufferSize))
+		pipe.Wait(l.Run(context.Background(), bufferSize, options...))
+		pipe.Wait(l.Close())
+	}
+}
+
+func TestMutation(t *testing.T) {
+	tests := []struct {
+		sinks []*mock.Sink
+		err   error
+	}{
+		{
+			sinks: []*mock.Sink{
+				&mock.Sink{Mutate: true, Mutable: true},
+				&mock.Sink{},
+			},
+		},
+		{
+			sinks: []*mock.Sink{
+				&mock.Sink{Mutate: true},
+			},
+			err: pipe.ErrMutation,
+		},
+		{
+			// shared sinks are called one at a time in debug mode
+			sinks: []*mock.Sink{
+				&mock.Sink{},
+				&mock.Sink{Mutate: true},
+			},
+			err: pipe.ErrMutation,
+		},
+	}
+	lineTests := make([]lineTest, 0, len(tests))
+	for _, test := range tests {
+		test := test
+		pump := &mock.Pump{
+			Limit:       10 * bufferSize,
+			NumChannels: 2,
+			Value:       0.5,
+		}
+		sinks := make([]pipe.Sink, 0, len(test.sinks))
+		for _, sink := range test.sinks {
+			sinks = append(sinks, sink)
+		}
+		lineTests = append(lineTests, lineTest{
+			line: &pipe.Line{
+				Pump:  pump,
+				Sinks: sinks,
+			},
+			options: []pipe.RunOption{pipe.WithDebug()},
+			err:     test.err,
+			check: func(t *testing.T) {
+				if test.err != nil {
+					return
+				}
+				for _, sink := range test.sinks {
+					b := sink.Buffer()
+					assert.Equal(t, 2, b.NumChannels())
+					assert.Equal(t, pump.Limit, b.Size())
+					for i := range b {
+						for _, v := range b[i] {
+							assert.Equal(t, pump.Value, v)
+						}
+					}
+				}
+			},
+		})
+	}
+	runLineTests(t, lineTests)
+}
+
+// plainProcessor doesn't have access to metadata.
+type plainProcessor struct{}
+
+func (plainProcessor) Process(string, signal.SampleRate, int) (func(signal.Float64) error, error) {
+	return func(signal.Float64) error {
+		return nil
+	}, nil
+}
+
+func TestSilence(t *testing.T) {
+	tests := []struct {
+		processor pipe.Processor
+		processed int
+		silent    int
+	}{
+		{
+			processor: &mock.SilenceSkipper{},
+			processed: 0,
+			silent:    10,
+		},
+		{
+			// processed buffers are not silent
+			processor: &mock.SilenceSkipper{Tail: 2*bufferSize + 1},
+			processed: 3 * bufferSize,
+			silent:    7,
+		},
+		{
+			processor: &mock.Processor{},
+			processed: 10 * bufferSize,
+			silent:    0,
+		},
+		{
+			processor: &mock.Float32Processor{},
+			processed: 10 * bufferSize,
+			silent:    0,
+		},
+		{
+			processor: &mock.InterFloat64Processor{},
+			processed: 10 * bufferSize,
+			silent:    0,
+		},
+		{
+			processor: plainProcessor{},
+			silent:    0,
+		},
+	}
+	lineTests := make([]lineTest, 0, len(tests))
+	for _, test := range tests {
+		test := test
+		sink := &mock.Sink{}
+		var counted []counter
+		if c, ok := test.processor.(counter); ok {
+			counted = append(counted, c)
+		}
+		lineTests = append(lineTests, lineTest{
+			name: fmt.Sprintf("%T", test.processor),
+			line: &pipe.Line{
+				Pump: &mock.Pump{
+					Limit:       10 * bufferSize,
+					NumChannels: 1,
+					Silent:      true,
+				},
+				Processors: pipe.Processors(test.processor),
+				Sinks:      pipe.Sinks(sink),
+			},
+			counted: counted,
+			samples: test.processed,
+			check: func(t *testing.T) {
+				var silent int
+				for _, d := range sink.Meta() {
+					if d.Silent {
+						silent++
+					}
+				}
+				assert.Equal(t, 10, len(sink.Meta()))
+				assert.Equal(t, test.silent, silent)
+			},
+		})
+	}
+	runLineTests(t, lineTests)
+}
+
+func TestRunOptions(t *testing.T) {
+	pump := &mock.Pump{
+		Limit:       10 * bufferSize,
+		NumChannels: 2,
+		SampleRate:  44100,
+		Value:       0.5,
+	}
+	queueSinks := []*mock.Sink{{}, {}}
+	ringSinks := []*mock.Sink{{}, {}}
+	ringPump := &mock.Pump{
+		Limit:       10*bufferSize + 10,
+		NumChannels: 2,
+		SampleRate:  44100,
+		Value:       0.5,
+	}
+	poolSinks := []*mock.Sink{{}, {Mutable: true}}
+	debugSink := &mock.Sink{}
+	runLineTests(t, []lineTest{
+		{
+			name: "pool limit",
+			line: &pipe.Line{
+				Pump: &mock.Pump{
+					Limit:       100 * bufferSize,
+					NumChannels: 2,
+					SampleRate:  44100,
+					Value:       0.5,
+				},
+				Processors: pipe.Processors(&mock.Resampler{OutputSampleRate: 88200}),
+				Sinks:      pipe.Sinks(poolSinks[0], poolSinks[1]),
+			},
+			options: []pipe.RunOption{pipe.WithPoolLimit(1)},
+			counted: []counter{poolSinks[0], poolSinks[1]},
+			samples: 200 * bufferSize,
+		},
+		{
+			// all buffers are released and none is used after release
+			name: "debug buffers",
+			line: &pipe.Line{
+				Pump: &mock.Pump{
+					Limit:       10*bufferSize + 1,
+					NumChannels: 2,
+					SampleRate:  44100,
+					Value:       0.5,
+				},
+				Processors: pipe.Processors(
+					&mock.Resampler{OutputSampleRate: 22050},
+					&mock.Remixer{OutputNumChannels: 1},
+				),
+				Sinks: pipe.Sinks(
+					debugSink,
+					&mock.Sink{Mutable: true, Mutate: true},
+					&mock.IntSink{BitDepth: signal.BitDepth16},
+				),
+			},
+			options: []pipe.RunOption{pipe.WithDebug()},
+			counted: []counter{debugSink},
+			samples: 5*bufferSize + 1,
+		},
+		{
+			name: "queue depth",
+			line: &pipe.Line{
+				Pump:       pump,
+				Processors: pipe.Processors(&mock.Processor{}),
+				Sinks:      pipe.Sinks(queueSinks[0], queueSinks[1]),
+			},
+			options: []pipe.RunOption{
+				pipe.WithQueueDepth(4),
+				pipe.WithComponentQueueDepth(queueSinks[1], 0),
+			},
+			counted: []counter{queueSinks[0], queueSinks[1]},
+			samples: pump.Limit,
+			running: func(t *testing.T, _ *pipe.Pipe) {
+				queue := 4 * pump.SampleRate.DurationOf(bufferSize)
+				assert.Equal(t, fmt.Sprintf("%q", queue), metric.Get(pump)[metric.QueueCounter])
+			},
+		},
+		{
+			name: "ring transport",
+			line: &pipe.Line{
+				Pump:       ringPump,
+				Processors: pipe.Processors(&mock.Processor{}),
+				Sinks:      pipe.Sinks(ringSinks[0], ringSinks[1]),
+			},
+			options: []pipe.RunOption{
+				pipe.WithTransport(pipe.RingTransport),
+				pipe.WithQueueDepth(2),
+				pipe.WithComponentQueueDepth(ringSinks[1], 0),
+				pipe.WithDebug(),
+			},
+			counted: []counter{ringSinks[0], ringSinks[1]},
+			samples: ringPump.Limit,
+		},
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	proc1 := &mock.Processor{}
+	proc2 := &mock.Processor{}
+	line1 := &pipe.Line{
+		Pump: &mock.Pump{
+			Limit:       10 * bufferSize,
+			NumChannels: 1,
+			SampleRate:  44100,
+		},
+		Processors: pipe.Processors(proc1),
+		Sinks:      pipe.Sinks(&mock.Sink{}),
+	}
+	line2 := &pipe.Line{
+		Pump:       &mock.Pump{Limit: 5 * bufferSize, NumChannels: 1},
+		Processors: pipe.Processors(proc2),
+		Sinks:      pipe.Sinks(&mock.Sink{}),
+	}
+	l, err := pipe.New(line1, line2)
+	assert.Nil(t, err)
+	err = pipe.Wait(l.Run(context.Background(), bufferSize))
+	assert.Nil(t, err)
+
+	id1, _ := l.ComponentID(proc1)
+	id2, _ := l.ComponentID(proc2)
+	values1, values2 := metric.GetComponent(id1), metric.GetComponent(id2)
+	assert.Equal(t, fmt.Sprint(10*bufferSize), values1[metric.SampleCounter])
+	assert.Equal(t, fmt.Sprint(5*bufferSize), values2[metric.SampleCounter])
+	assert.Equal(t, "mock.Processor", values1[metric.TypeLabel])
+	assert.NotEqual(t, values1[metric.LineLabel], values2[metric.LineLabel])
+	assert.Equal(t, 3, len(metric.GetLine(values1[metric.LineLabel])))
+	assert.Equal(t, "2", metric.Get(proc1)[metric.ComponentCounter])
+	// processing time is measured for each line
+	assert.NotEqual(t, `"0s"`, values1[metric.ProcessingMaxCounter])
+	assert.True(t, metric.GetRealTimeFactor(values1[metric.LineLabel]) > 0)
+
+	// metrics are dropped when pipe is closed
+	pipe.Wait(l.Close())
+	assert.Nil(t, metric.GetComponent(id1))
+	assert.Nil(t, metric.GetComponent(id2))
+	assert.Equal(t, 0, len(metric.Get(proc1)))
+
+	// metrics of running pipe are dropped once it's interrupted
+	l, err = pipe.New(&pipe.Line{
+		Pump:       &mock.Pump{Limit: math.MaxInt32, NumChannels: 1},
+		Processors: pipe.Processors(proc1),
+		Sinks:      pipe.Sinks(&mock.Sink{}),
+	})
+	assert.Nil(t, err)
+	errc := l.Run(context.Background(), bufferSize)
+	id1, _ = l.ComponentID(proc1)
+	assert.Eventually(t, func() bool {
+		messages := metric.GetComponent(id1)[metric.MessageCounter]
+		return messages != "" && messages != "0"
+	}, time.Second, time.Millisecond)
+	assert.Nil(t, pipe.Wait(l.Close()))
+	assert.Nil(t, metric.GetComponent(id1))
+	pipe.Wait(errc)
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := metric.NewMemory()
+	var tests []lineTest
+	for _, newPipe := range []func(...*pipe.Line) (*pipe.Pipe, error){
+		pipe.Config{Recorder: recorder}.New,
+		pipe.Config{Recorder: recorder}.NewFused,
+	} {
+		proc := &mock.Processor{}
+		var id string
+		tests = append(tests, lineTest{
+			newPipe: newPipe,
+			line: &pipe.Line{
+				Pump:       &mock.Pump{Limit: 10 * bufferSize, NumChannels: 1},
+				Processors: pipe.Processors(proc),
+				Sinks:      pipe.Sinks(&mock.Sink{}),
+			},
+			running: func(t *testing.T, l *pipe.Pipe) {
+				id, _ = l.ComponentID(proc)
+				// metrics are not published into default recorder
+				assert.Nil(t, metric.GetComponent(id))
+				line, componentType, ok := recorder.Labels(id)
+				assert.True(t, ok)
+				assert.Equal(t, "mock.Processor", componentType)
+				assert.Equal(t, 3, len(recorder.Components(line)))
+				measurements := recorder.Measurements(id)
+				assert.Equal(t, 10, len(measurements))
+				for _, m := range measurements {
+					assert.Equal(t, bufferSize, m.Samples)
+				}
+			},
+			check: func(t *testing.T) {
+				assert.Nil(t, recorder.Measurements(id))
+			},
+		})
+	}
+	runLineTests(t, tests)
+}
+
+func TestLevels(t *testing.T) {
+	recorder := metric.NewMemory()
+	sampleRate := signal.SampleRate(44100)
+	for _, newPipe := range []func(...*pipe.Line) (*pipe.Pipe, error){
+		pipe.Config{Recorder: recorder}.New,
+		pipe.Config{Recorder: recorder}.NewFused,
+	} {
+		pump := &mock.Pump{
+			Limit:       10 * bufferSize,
+			NumChannels: 2,
+			SampleRate:  sampleRate,
+			Value:       1.5,
+		}
+		proc := &mock.Processor{}
+		sink := &mock.Sink{}
+		l, err := newPipe(&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(proc),
+			Sinks:      pipe.Sinks(sink),
+		})
+		assert.Nil(t, err)
+		// levels are not metered by default
+		err = pipe.Wait(l.Run(context.Background(), bufferSize))
+		assert.Nil(t, err)
+		pumpID, _ := l.ComponentID(pump)
+		assert.Nil(t, recorder.Levels(pumpID))
+
+		// levels are recorded every 5 buffers
+		interval := sampleRate.DurationOf(5 * bufferSize)
+		err = pipe.Wait(l.Run(context.Background(), bufferSize, pipe.WithLevels(interval)))
+		assert.Nil(t, err)
+		for _, component := range []interface{}{pump, proc} {
+			id, _ := l.ComponentID(component)
+			levels := recorder.Levels(id)
+			assert.Equal(t, 2, len(levels))
+			for _, channels := range levels {
+				assert.Equal(t, 2, len(channels))
+				for _, level := range channels {
+					assert.Equal(t, metric.Level{Peak: 1.5, RMS: 1.5, DC: 1.5, Clips: 5 * bufferSize}, level)
+				}
+			}
+		}
+		// sinks don't have output
+		sinkID, _ := l.ComponentID(sink)
+		assert.Nil(t, recorder.Levels(sinkID))
 		pipe.Wait(l.Close())
 	}
 }
+
+func TestReport(t *testing.T) {
+	sampleRate := signal.SampleRate(44100)
+	testError := errors.New("test error")
+	for _, newPipe := range []func(...*pipe.Line) (*pipe.Pipe, error){
+		pipe.New,
+		pipe.NewFused,
+	} {
+		pump := &mock.Pump{
+			Limit:       10*bufferSize + 10,
+			NumChannels: 1,
+			SampleRate:  sampleRate,
+		}
+		proc := &mock.Processor{}
+		sink := &mock.Sink{}
+		l, err := newPipe(&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(proc),
+			Sinks:      pipe.Sinks(sink),
+		})
+		assert.Nil(t, err)
+		reports := make(chan pipe.Report, 1)
+		withReport := pipe.WithReport(func(r pipe.Report) { reports <- r })
+
+		// run until the end of signal
+		err = pipe.Wait(l.Run(context.Background(), bufferSize, withReport))
+		assert.Nil(t, err)
+		report := <-reports
+		assert.Equal(t, pipe.StopEOF, report.Reason)
+		assert.Equal(t, sampleRate.DurationOf(pump.Limit), report.Duration)
+		assert.True(t, report.Wall > 0)
+		assert.Nil(t, report.Errors)
+		assert.Equal(t, 3, len(report.Components))
+		assert.Equal(t, pump, report.Components[0].Component)
+		for _, component := range []interface{}{pump, proc, sink} {
+			c, ok := report.Component(component)
+			assert.True(t, ok)
+			id, _ := l.ComponentID(component)
+			assert.Equal(t, id, c.ID)
+			assert.Equal(t, 11, c.Messages)
+			assert.Equal(t, pump.Limit, c.Samples)
+			assert.Equal(t, pipe.HookReport{Called: true}, c.Reset)
+			assert.Equal(t, pipe.HookReport{Called: true}, c.Flush)
+			assert.False(t, c.Interrupt.Called)
+		}
+		_, ok := report.Component(&mock.Sink{})
+		assert.False(t, ok)
+
+		// run fails
+		proc.ErrorOnCall = testError
+		feedback := l.Run(context.Background(), bufferSize, withReport)
+		err = pipe.Wait(feedback)
+		assert.True(t, errors.Is(err, testError))
+		// wait until the run is done
+		for range feedback {
+		}
+		report = <-reports
+		assert.Equal(t, pipe.StopError, report.Reason)
+		assert.Equal(t, 1, len(report.Errors))
+		assert.True(t, errors.Is(report.Errors[0], testError))
+		proc.ErrorOnCall = nil
+
+		// run is limited, the last buffer is trimmed
+		limit := 5*bufferSize + 5
+		err = pipe.Wait(l.Run(context.Background(), bufferSize, withReport, pipe.WithLimit(sampleRate.DurationOf(limit))))
+		assert.Nil(t, err)
+		report = <-reports
+		assert.Equal(t, pipe.StopLimit, report.Reason)
+		assert.Equal(t, sampleRate.DurationOf(limit), report.Duration)
+		for _, component := range []interface{}{pump, proc, sink} {
+			c, _ := report.Component(component)
+			assert.Equal(t, 6, c.Messages)
+			assert.Equal(t, limit, c.Samples)
+		}
+
+		// run is limited at the end of buffer
+		limit = 5 * bufferSize
+		err = pipe.Wait(l.Run(context.Background(), bufferSize, withReport, pipe.WithLimit(sampleRate.DurationOf(limit))))
+		assert.Nil(t, err)
+		report = <-reports
+		assert.Equal(t, pipe.StopLimit, report.Reason)
+		c, _ := report.Component(sink)
+		assert.Equal(t, 5, c.Messages)
+		assert.Equal(t, limit, c.Samples)
+
+		// signal ends exactly at the limit
+		err = pipe.Wait(l.Run(context.Background(), bufferSize, withReport, pipe.WithLimit(sampleRate.DurationOf(pump.Limit))))
+		assert.Nil(t, err)
+		report = <-reports
+		assert.Equal(t, pipe.StopEOF, report.Reason)
+		c, _ = report.Component(sink)
+		assert.Equal(t, pump.Limit, c.Samples)
+
+		// run is cancelled, errors of interrupt hooks are reported by hooks
+		pump.ErrorOnInterrupt = testError
+		ctx, cancelFn := context.WithCancel(context.Background())
+		cancelFn()
+		pipe.Wait(l.Run(ctx, bufferSize, withReport))
+		report = <-reports
+		assert.Equal(t, pipe.StopCancel, report.Reason)
+		c, _ = report.Component(pump)
+		assert.True(t, c.Interrupt.Called)
+		assert.True(t, errors.Is(c.Interrupt.Err, testError))
+		pump.ErrorOnInterrupt = nil
+		pipe.Wait(l.Close())
+	}
+}
+
+func TestFused(t *testing.T) {
+	pump := &mock.Pump{
+		Limit:       10*bufferSize + 10,
+		NumChannels: 2,
+		SampleRate:  22050,
+		Value:       0.5,
+	}
+	proc := &mock.Processor{}
+	sink1 := &mock.Sink{}
+	sink2 := &mock.Sink{Mutable: true, Mutate: true}
+	sink3 := &mock.IntSink{BitDepth: signal.BitDepth16}
+	sinkErr := &mock.Sink{ErrorOnCall: errors.New("sink error")}
+	runLineTests(t, []lineTest{
+		{
+			newPipe: pipe.NewFused,
+			line: &pipe.Line{
+				Pump:       pump,
+				Processors: pipe.Processors(&mock.Resampler{OutputSampleRate: 44100}, proc),
+				Sinks:      pipe.Sinks(sink1, sink2, sink3),
+			},
+			options: []pipe.RunOption{pipe.WithDebug()},
+			counted: []counter{proc, sink1, sink2, &sink3.Mock},
+			samples: 2 * pump.Limit,
+			start: func(t *testing.T, l *pipe.Pipe) {
+				// params are delivered before the run
+				pumpID, _ := l.ComponentID(pump)
+				l.Push(pumpID, pump.ValueParam(0.25))
+			},
+			check: func(t *testing.T) {
+				assert.True(t, pump.Resetted)
+				assert.True(t, pump.Flushed)
+				for _, sink := range []*mock.Sink{sink1, sink2, &sink3.Mock} {
+					assert.True(t, sink.Resetted)
+					assert.True(t, sink.Flushed)
+				}
+				assert.Equal(t, 0.25, sink1.Buffer()[1][2*pump.Limit-1])
+				assert.Equal(t, 0.25, sink2.Buffer()[0][0])
+			},
+		},
+		{
+			// errors stop the fused line
+			newPipe: pipe.NewFused,
+			line: &pipe.Line{
+				Pump:  &mock.Pump{Limit: 10 * bufferSize, NumChannels: 1},
+				Sinks: pipe.Sinks(sinkErr),
+			},
+			options: []pipe.RunOption{pipe.WithDebug()},
+			err:     sinkErr.ErrorOnCall,
+		},
+	})
+}
+
+func TestChannelProcessor(t *testing.T) {
+	numChannels := 8
+	s := pipe.NewScheduler(1)
+	defer s.Close()
+	var tests []lineTest
+	for _, newPipe := range []func(...*pipe.Line) (*pipe.Pipe, error){
+		pipe.New,
+		pipe.NewFused,
+	} {
+		pump := &mock.Pump{
+			Limit:       10*bufferSize + 1,
+			NumChannels: numChannels,
+			SampleRate:  44100,
+			Value:       0.5,
+		}
+		proc := &mock.ChannelProcessor{Gain: 2}
+		sink := &mock.Sink{}
+		tests = append(tests, lineTest{
+			newPipe: newPipe,
+			line: &pipe.Line{
+				Pump:       pump,
+				Processors: pipe.Processors(proc),
+				Sinks:      pipe.Sinks(sink),
+			},
+			check: func(t *testing.T) {
+				assert.Equal(t, numChannels, len(proc.ChannelSamples()))
+				for _, samples := range proc.ChannelSamples() {
+					assert.Equal(t, pump.Limit, samples)
+				}
+				buffer := sink.Buffer()
+				assert.Equal(t, numChannels, buffer.NumChannels())
+				for i := range buffer {
+					assert.Equal(t, 1.0, buffer[i][pump.Limit-1])
+				}
+			},
+		})
+	}
+	// processors of the run share a single worker
+	for _, newPipe := range []func(...*pipe.Line) (*pipe.Pipe, error){
+		pipe.New,
+		func(ls ...*pipe.Line) (*pipe.Pipe, error) { return pipe.NewScheduled(s, 1, ls...) },
+	} {
+		sink := &mock.Sink{}
+		tests = append(tests, lineTest{
+			newPipe: newPipe,
+			line: &pipe.Line{
+				Pump:       &mock.Pump{Limit: 10 * bufferSize, NumChannels: numChannels, Value: 0.25},
+				Processors: pipe.Processors(&mock.ChannelProcessor{Gain: 2}, &mock.ChannelProcessor{Gain: 2}),
+				Sinks:      pipe.Sinks(sink),
+			},
+			options: []pipe.RunOption{pipe.WithChannelWorkers(1)},
+			check: func(t *testing.T) {
+				buffer := sink.Buffer()
+				for i := range buffer {
+					assert.Equal(t, 1.0, buffer[i][0])
+				}
+			},
+		})
+	}
+	// channel errors stop the line
+	proc := &mock.ChannelProcessor{}
+	proc.ErrorOnCall = errors.New("channel error")
+	tests = append(tests, lineTest{
+		line: &pipe.Line{
+			Pump:       &mock.Pump{Limit: bufferSize, NumChannels: numChannels},
+			Processors: pipe.Processors(proc),
+			Sinks:      pipe.Sinks(&mock.Sink{}),
+		},
+		err: proc.ErrorOnCall,
+	})
+	runLineTests(t, tests)
+}
+
+func TestScheduler(t *testing.T) {
+	s := pipe.NewScheduler(2)
+	defer s.Close()
+
+	numPipes := 10
+	sinks := make([]*mock.Sink, numPipes)
+	pipes := make([]*pipe.Pipe, numPipes)
+	for i := range pipes {
+		sinks[i] = &mock.Sink{}
+		l, err := pipe.NewScheduled(s, i%3,
+			&pipe.Line{
+				Pump:       &mock.Pump{Limit: 10*bufferSize + i, NumChannels: 1},
+				Processors: pipe.Processors(&mock.Processor{}),
+				Sinks:      pipe.Sinks(sinks[i]),
+			},
+		)
+		assert.Nil(t, err)
+		pipes[i] = l
+	}
+	runs := make([]chan error, numPipes)
+	for i, l := range pipes {
+		runs[i] = l.Run(context.Background(), bufferSize)
+	}
+	for i := range runs {
+		assert.Nil(t, pipe.Wait(runs[i]))
+		_, samples := sinks[i].Count()
+		assert.Equal(t, 10*bufferSize+i, samples)
+		pipe.Wait(pipes[i].Close())
+	}
+
+	// scheduled pipes can be paused and resumed
+	pump := &mock.Pump{Limit: 1000 * bufferSize, NumChannels: 1}
+	l, err := pipe.NewScheduled(s, 1,
+		&pipe.Line{
+			Pump:  pump,
+			Sinks: pipe.Sinks(&mock.Sink{Discard: true}),
+		},
+	)
+	assert.Nil(t, err)
+	runc := l.Run(context.Background(), bufferSize)
+	assert.Nil(t, pipe.Wait(l.Pause()))
+	assert.Nil(t, pipe.Wait(runc))
+	assert.Nil(t, pipe.Wait(l.Resume()))
+	assert.Nil(t, pipe.Wait(l.Close()))
+}
+
+// flushSink notifies when it's flushed.
+type flushSink struct {
+	sink    *mock.Sink
+	flushed chan struct{}
+}
+
+func (s flushSink) Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error) {
+	return s.sink.Sink(pipeID, sampleRate, numChannels)
+}
+
+func (s flushSink) Flush(pipeID string) error {
+	close(s.flushed)
+	return s.sink.Flush(pipeID)
+}
+
+func TestSchedulerPause(t *testing.T) {
+	// single worker must not be blocked by paused pipe
+	s := pipe.NewScheduler(1)
+	defer s.Close()
+
+	pausedSink := &mock.Sink{Discard: true}
+	flushed := make(chan struct{})
+	paused, err := pipe.NewScheduled(s, 1,
+		&pipe.Line{
+			Pump:  &mock.Pump{Limit: 1000 * bufferSize, NumChannels: 1},
+			Sinks: pipe.Sinks(flushSink{sink: pausedSink, flushed: flushed}),
+		},
+	)
+	assert.Nil(t, err)
+	paused.Run(context.Background(), bufferSize)
+	assert.Nil(t, pipe.Wait(paused.Pause()))
+	_, before := pausedSink.Count()
+
+	sink := &mock.Sink{}
+	l, err := pipe.NewScheduled(s, 1,
+		&pipe.Line{
+			Pump:  &mock.Pump{Limit: 10 * bufferSize, NumChannels: 1},
+			Sinks: pipe.Sinks(sink),
+		},
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, pipe.Wait(l.Run(context.Background(), bufferSize)))
+	_, samples := sink.Count()
+	assert.Equal(t, 10*bufferSize, samples)
+	assert.Nil(t, pipe.Wait(l.Close()))
+
+	// paused pipe continues after resume
+	_, after := pausedSink.Count()
+	assert.Equal(t, before, after)
+	assert.Nil(t, pipe.Wait(paused.Resume()))
+	select {
+	case <-flushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("paused pipe is not done after resume")
+	}
+	_, samples = pausedSink.Count()
+	assert.Equal(t, 1000*bufferSize, samples)
+	assert.Nil(t, pipe.Wait(paused.Close()))
+
+	// paused pipe is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	paused, err = pipe.NewScheduled(s, 1,
+		&pipe.Line{
+			Pump:  &mock.Pump{Limit: 1000 * bufferSize, NumChannels: 1},
+			Sinks: pipe.Sinks(&mock.Sink{Discard: true}),
+		},
+	)
+	assert.Nil(t, err)
+	paused.Run(ctx, bufferSize)
+	assert.Nil(t, pipe.Wait(paused.Pause()))
+	cancel()
+	// parked job is done, so scheduler can be closed
+	assert.Nil(t, pipe.Wait(paused.Close()))
+}
+
+func TestStepper(t *testing.T) {
+	pump := &mock.Pump{
+		Limit:       3 * bufferSize,
+		NumChannels: 1,
+		Value:       1,
+	}
+	proc := &mock.Processor{}
+	sink := &mock.Sink{}
+	s, err := pipe.NewStepper(
+		&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(proc),
+			Sinks:      pipe.Sinks(sink),
+		},
+	)
+	assert.Nil(t, err)
+	_, err = s.Step()
+	assert.True(t, errors.Is(err, state.ErrInvalidState))
+
+	assert.Nil(t, s.Run(bufferSize, pipe.WithDebug()))
+	assert.False(t, pump.Resetted)
+	running, err := s.Step()
+	assert.True(t, running)
+	assert.Nil(t, err)
+	assert.True(t, pump.Resetted)
+
+	// params are delivered with the next buffer
+	pumpID, _ := s.ComponentID(pump)
+	s.Push(pumpID, pump.ValueParam(2))
+	_, samples := sink.Count()
+	assert.Equal(t, bufferSize, samples)
+	running, err = s.Step()
+	assert.True(t, running)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, sink.Buffer()[0][bufferSize-1])
+	assert.Equal(t, 2.0, sink.Buffer()[0][bufferSize])
+
+	// pause keeps the run
+	assert.Nil(t, s.Pause())
+	assert.False(t, pump.Interrupted)
+	assert.False(t, sink.Flushed)
+	_, err = s.Step()
+	assert.True(t, errors.Is(err, state.ErrInvalidState))
+
+	// resume continues the run
+	pump.Resetted = false
+	assert.Nil(t, s.Resume())
+	steps := 0
+	for running = true; running; steps++ {
+		running, err = s.Step()
+		assert.Nil(t, err)
+	}
+	// the last step receives EOF
+	assert.Equal(t, 2, steps)
+	assert.False(t, pump.Resetted)
+	assert.False(t, pump.Interrupted)
+	assert.True(t, sink.Flushed)
+	_, samples = sink.Count()
+	assert.Equal(t, pump.Limit, samples)
+	for i, m := range sink.Meta() {
+		assert.Equal(t, int64(i*bufferSize), m.Position)
+	}
+	assert.Equal(t, 3, len(sink.Meta()))
+
+	// paused run is interrupted on close
+	assert.Nil(t, s.Run(bufferSize))
+	running, err = s.Step()
+	assert.True(t, running)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Pause())
+	assert.Nil(t, s.Close())
+	assert.True(t, pump.Interrupted)
+
+	s, err = pipe.NewStepper(
+		&pipe.Line{
+			Pump:       pump,
+			Processors: pipe.Processors(proc),
+			Sinks:      pipe.Sinks(sink),
+		},
+	)
+	assert.Nil(t, err)
+
+	// errors end the run
+	assert.Nil(t, s.Run(bufferSize))
+	sink.ErrorOnCall = errors.New("sink error")
+	running, err = s.Step()
+	assert.False(t, running)
+	assert.True(t, errors.Is(err, sink.ErrorOnCall))
+
+	assert.Nil(t, s.Close())
+	assert.True(t, errors.Is(s.Close(), state.ErrInvalidState))
+}
+
+// reusingSink writes into the buffer it received on the previous call,
+// which is already released. It fails on the call number FailOn.
+type reusingSink struct {
+	previous signal.Float64
+	calls    int
+	FailOn   int
+	Err      error
+}
+
+func (s *reusingSink) Sink(string, signal.SampleRate, int) (func(signal.Float64) error, error) {
+	return func(b signal.Float64) error {
+		s.calls++
+		if s.previous != nil {
+			s.previous[0][0] = 1
+		}
+		s.previous = b
+		if s.calls == s.FailOn {
+			return s.Err
+		}
+		return nil
+	}, nil
+}
+
+func TestStepperDebug(t *testing.T) {
+	// remixer keeps two buffers of the same shape in turn
+	sink := &reusingSink{FailOn: 3, Err: errors.New("sink error")}
+	s, err := pipe.NewStepper(
+		&pipe.Line{
+			Pump:       &mock.Pump{Limit: 10 * bufferSize, NumChannels: 2},
+			Processors: pipe.Processors(&mock.Remixer{OutputNumChannels: 2}),
+			Sinks:      pipe.Sinks(sink),
+		},
+	)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Run(bufferSize, pipe.WithDebug()))
+	for {
+		running, err := s.Step()
+		if running {
+			continue
+		}
+		// misuse of buffers is reported with the error of line
+		assert.True(t, errors.Is(err, sink.Err))
+		assert.True(t, errors.Is(err, pipe.ErrUseAfterFree))
+		break
+	}
+	assert.Nil(t, s.Close())
+}
+
+func TestStepperReport(t *testing.T) {
+	sampleRate := signal.SampleRate(44100)
+	pump := &mock.Pump{Limit: 3 * bufferSize, NumChannels: 1, SampleRate: sampleRate}
+	sink := &mock.Sink{}
+	s, err := pipe.NewStepper(&pipe.Line{
+		Pump:  pump,
+		Sinks: pipe.Sinks(sink),
+	})
+	assert.Nil(t, err)
+	var reports []pipe.Report
+	withReport := pipe.WithReport(func(r pipe.Report) { reports = append(reports, r) })
+	stepAll := func() (err error) {
+		for running := true; running; {
+			running, err = s.Step()
+		}
+		return err
+	}
+
+	// report is made by the last step
+	assert.Nil(t, s.Run(bufferSize, withReport))
+	assert.Nil(t, stepAll())
+	assert.Equal(t, 1, len(reports))
+	assert.Equal(t, pipe.StopEOF, reports[0].Reason)
+	c, _ := reports[0].Component(sink)
+	assert.Equal(t, 3*bufferSize, c.Samples)
+
+	assert.Nil(t, s.Run(bufferSize, withReport, pipe.WithLimit(sampleRate.DurationOf(bufferSize+1))))
+	assert.Nil(t, stepAll())
+	assert.Equal(t, pipe.StopLimit, reports[1].Reason)
+	assert.Equal(t, sampleRate.DurationOf(bufferSize+1), reports[1].Duration)
+
+	sink.ErrorOnCall = errors.New("sink error")
+	assert.Nil(t, s.Run(bufferSize, withReport))
+	assert.True(t, errors.Is(stepAll(), sink.ErrorOnCall))
+	assert.Equal(t, pipe.StopError, reports[2].Reason)
+	assert.Equal(t, 1, len(reports[2].Errors))
+	sink.ErrorOnCall = nil
+
+	// interrupted run is reported on close
+	assert.Nil(t, s.Run(bufferSize, withReport))
+	running, err := s.Step()
+	assert.True(t, running)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Close())
+	assert.Equal(t, 4, len(reports))
+	assert.Equal(t, pipe.StopCancel, reports[3].Reason)
+	c, _ = reports[3].Component(pump)
+	assert.True(t, c.Interrupt.Called)
+}
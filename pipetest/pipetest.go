@@ -0,0 +1,170 @@
+// Package pipetest provides utilities to test pipe components.
+package pipetest
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/metric"
+)
+
+// StepperAllocsPerBuffer runs lines with pipe.Stepper and returns the
+// average number of heap allocations per buffer. Only fused execution
+// on the calling goroutine is measured, use PipeAllocsPerBuffer to
+// cover queues and goroutines of pipe.Pipe. Lines are stepped once to
+// warm up pools and components and then for provided number of
+// buffers. An error is returned if lines fail or are done before all
+// buffers are processed. Lines must not be used by running pipes.
+func StepperAllocsPerBuffer(buffers, bufferSize int, lines ...*pipe.Line) (float64, error) {
+	s, err := pipe.NewStepper(lines...)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	if err := s.Run(bufferSize); err != nil {
+		return 0, err
+	}
+	var (
+		steps   int
+		running = true
+	)
+	allocs := testing.AllocsPerRun(buffers, func() {
+		if !running {
+			return
+		}
+		running, err = s.Step()
+		steps++
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error running lines: %w", err)
+	}
+	if !running {
+		// warm up step is not counted
+		return 0, fmt.Errorf("lines are done after %d of %d buffers", steps-1, buffers)
+	}
+	return allocs, nil
+}
+
+// AssertStepperAllocs fails the test if lines allocate more than max
+// times per buffer on average. It uses StepperAllocsPerBuffer and
+// should not be called from parallel tests.
+func AssertStepperAllocs(t testing.TB, max float64, buffers, bufferSize int, lines ...*pipe.Line) {
+	t.Helper()
+	allocs, err := StepperAllocsPerBuffer(buffers, bufferSize, lines...)
+	if err != nil {
+		t.Fatalf("error measuring allocations: %v", err)
+		return
+	}
+	if allocs > max {
+		t.Errorf("allocations per buffer: got %v, want at most %v", allocs, max)
+	}
+}
+
+// PipeAllocsPerBuffer runs lines with pipe.Pipe created by pipe.New and
+// returns the average number of heap allocations per buffer. Buffers
+// are counted at the pump of the first line, allocations of all
+// goroutines of the process are measured. Lines run for provided number
+// of buffers to warm up pools and components and then allocations of
+// at least the same number of buffers are measured. An error is
+// returned if lines fail or are done before all buffers are processed.
+// Lines must not be used by running pipes.
+func PipeAllocsPerBuffer(buffers, bufferSize int, lines []*pipe.Line, options ...pipe.RunOption) (float64, error) {
+	counter := bufferCounter{}
+	p, err := pipe.Config{Recorder: &counter}.New(lines...)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := p.Run(ctx, bufferSize, options...)
+	defer func() {
+		cancel()
+		pipe.Wait(errc)
+		pipe.Wait(p.Close())
+	}()
+
+	// ticker is created upfront, so polling doesn't allocate
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	var before, after runtime.MemStats
+	if err := counter.wait(int64(buffers), errc, ticker.C); err != nil {
+		return 0, err
+	}
+	runtime.ReadMemStats(&before)
+	start := counter.buffers()
+	if err := counter.wait(start+int64(buffers), errc, ticker.C); err != nil {
+		return 0, err
+	}
+	runtime.ReadMemStats(&after)
+	measured := counter.buffers() - start
+	return float64((after.Mallocs - before.Mallocs) / uint64(measured)), nil
+}
+
+// AssertPipeAllocs fails the test if lines executed by pipe.Pipe
+// allocate more than max times per buffer on average. It uses
+// PipeAllocsPerBuffer and should not be called from parallel tests.
+func AssertPipeAllocs(t testing.TB, max float64, buffers, bufferSize int, lines []*pipe.Line, options ...pipe.RunOption) {
+	t.Helper()
+	allocs, err := PipeAllocsPerBuffer(buffers, bufferSize, lines, options...)
+	if err != nil {
+		t.Fatalf("error measuring allocations: %v", err)
+		return
+	}
+	if allocs > max {
+		t.Errorf("allocations per buffer: got %v, want at most %v", allocs, max)
+	}
+}
+
+// bufferCounter is a recorder that counts buffers of the first
+// registered component, which is the pump of the first line.
+type bufferCounter struct {
+	once sync.Once
+	n    int64
+}
+
+// Add implements metric.Recorder.
+func (c *bufferCounter) Add(line, id, componentType string) metric.ComponentRecorder {
+	var r metric.ComponentRecorder = discard{}
+	c.once.Do(func() {
+		r = c
+	})
+	return r
+}
+
+// Drop implements metric.Recorder.
+func (*bufferCounter) Drop(string) {}
+
+// Record implements metric.ComponentRecorder.
+func (c *bufferCounter) Record(metric.Measurement) {
+	atomic.AddInt64(&c.n, 1)
+}
+
+func (c *bufferCounter) buffers() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// wait blocks until provided number of buffers is counted. An error is
+// returned if the run is done before that.
+func (c *bufferCounter) wait(n int64, errc <-chan error, tick <-chan time.Time) error {
+	for c.buffers() < n {
+		select {
+		case err, ok := <-errc:
+			if ok && err != nil {
+				return fmt.Errorf("error running lines: %w", err)
+			}
+			return fmt.Errorf("lines are done after %d of %d buffers", c.buffers(), n)
+		case <-tick:
+		}
+	}
+	return nil
+}
+
+// discard is a component recorder that drops measurements.
+type discard struct{}
+
+func (discard) Record(metric.Measurement) {}
@@ -0,0 +1,142 @@
+package pipetest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe"
+	"pipelined.dev/pipe/internal/mock"
+	"pipelined.dev/pipe/pipetest"
+)
+
+const bufferSize = 512
+
+// recorder records failures of assertions.
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(string, ...interface{}) {
+	r.failed = true
+}
+
+func (r *recorder) Fatalf(string, ...interface{}) {
+	r.failed = true
+}
+
+// copySink allocates a copy of every received buffer.
+type copySink struct {
+	last signal.Float64
+}
+
+func (s *copySink) Sink(pipeID string, sampleRate signal.SampleRate, numChannels int) (func(signal.Float64) error, error) {
+	return func(b signal.Float64) error {
+		s.last = signal.Float64(nil).Append(b)
+		return nil
+	}, nil
+}
+
+func TestAllocs(t *testing.T) {
+	tests := []struct {
+		line   func() *pipe.Line
+		failed bool
+	}{
+		{
+			line: func() *pipe.Line {
+				return &pipe.Line{
+					Pump:       &mock.Pump{Limit: 1000 * bufferSize, NumChannels: 2, SampleRate: 44100},
+					Processors: pipe.Processors(&mock.Processor{}, &mock.ChannelProcessor{}, &mock.Resampler{OutputSampleRate: 48000}),
+					Sinks:      pipe.Sinks(&mock.Sink{Discard: true}, &mock.Sink{Discard: true, Mutable: true}, &mock.IntSink{BitDepth: signal.BitDepth16}),
+				}
+			},
+		},
+		{
+			line: func() *pipe.Line {
+				return &pipe.Line{
+					Pump:  &mock.Pump{Limit: 1000 * bufferSize, NumChannels: 2, SampleRate: 44100},
+					Sinks: pipe.Sinks(&copySink{}),
+				}
+			},
+			failed: true,
+		},
+		{
+			// pump is done before all buffers are processed
+			line: func() *pipe.Line {
+				return &pipe.Line{
+					Pump:  &mock.Pump{Limit: 10 * bufferSize, NumChannels: 2},
+					Sinks: pipe.Sinks(&mock.Sink{Discard: true}),
+				}
+			},
+			failed: true,
+		},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			r := recorder{TB: t}
+			pipetest.AssertStepperAllocs(&r, 0, 100, bufferSize, test.line())
+			assert.Equal(t, test.failed, r.failed)
+		})
+	}
+}
+
+func TestPipeAllocs(t *testing.T) {
+	tests := []struct {
+		line      func() *pipe.Line
+		transport pipe.Transport
+		failed    bool
+	}{
+		{
+			line: func() *pipe.Line {
+				return &pipe.Line{
+					Pump:       &mock.Pump{Limit: 100000 * bufferSize, NumChannels: 2, SampleRate: 44100},
+					Processors: pipe.Processors(&mock.Processor{}, &mock.ChannelProcessor{}, &mock.Resampler{OutputSampleRate: 48000}),
+					Sinks:      pipe.Sinks(&mock.Sink{Discard: true}, &mock.Sink{Discard: true, Mutable: true}, &mock.IntSink{BitDepth: signal.BitDepth16}),
+				}
+			},
+			transport: pipe.ChannelTransport,
+		},
+		{
+			line: func() *pipe.Line {
+				return &pipe.Line{
+					Pump:       &mock.Pump{Limit: 100000 * bufferSize, NumChannels: 2, SampleRate: 44100},
+					Processors: pipe.Processors(&mock.Processor{}, &mock.ChannelProcessor{}, &mock.Resampler{OutputSampleRate: 48000}),
+					Sinks:      pipe.Sinks(&mock.Sink{Discard: true}, &mock.Sink{Discard: true, Mutable: true}, &mock.IntSink{BitDepth: signal.BitDepth16}),
+				}
+			},
+			transport: pipe.RingTransport,
+		},
+		{
+			line: func() *pipe.Line {
+				return &pipe.Line{
+					Pump:  &mock.Pump{Limit: 100000 * bufferSize, NumChannels: 2, SampleRate: 44100},
+					Sinks: pipe.Sinks(&copySink{}),
+				}
+			},
+			failed: true,
+		},
+		{
+			// pump is done before all buffers are processed
+			line: func() *pipe.Line {
+				return &pipe.Line{
+					Pump:  &mock.Pump{Limit: 10 * bufferSize, NumChannels: 2},
+					Sinks: pipe.Sinks(&mock.Sink{Discard: true}),
+				}
+			},
+			failed: true,
+		},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			r := recorder{TB: t}
+			pipetest.AssertPipeAllocs(&r, 0, 1000, bufferSize, []*pipe.Line{test.line()}, pipe.WithTransport(test.transport))
+			assert.Equal(t, test.failed, r.failed)
+		})
+	}
+}
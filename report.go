@@ -0,0 +1,290 @@
+package pipe
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"pipelined.dev/signal"
+
+	"pipelined.dev/pipe/internal/runner"
+	"pipelined.dev/pipe/metric"
+)
+
+// StopReason is the reason why the run has stopped.
+type StopReason int
+
+const (
+	// StopEOF means that pumps of all lines reached the end of signal.
+	StopEOF StopReason = iota
+	// StopCancel means that the run was cancelled with context or
+	// interrupted by Close. Errors of interrupt hooks don't change the
+	// reason, they are reported by hooks of components.
+	StopCancel
+	// StopError means that one of components has failed.
+	StopError
+	// StopLimit means that signal of pumps was cut by the limit of the
+	// run. See WithLimit.
+	StopLimit
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopEOF:
+		return "EOF"
+	case StopCancel:
+		return "cancel"
+	case StopError:
+		return "error"
+	case StopLimit:
+		return "limit"
+	default:
+		return "unknown"
+	}
+}
+
+type (
+	// Report describes a completed run of the pipe.
+	Report struct {
+		Reason StopReason
+		// Wall is the time between the start of the run and the moment
+		// when all components are done.
+		Wall time.Duration
+		// Duration is the duration of signal pumped by the longest line.
+		Duration time.Duration
+		// Components of the same line are in order of pump, processors
+		// and sinks.
+		Components []ComponentReport
+		// Errors contains all errors returned by components.
+		Errors []error
+	}
+
+	// ComponentReport describes a component in the completed run.
+	ComponentReport struct {
+		ID        string
+		Line      string // id of the line that contains component
+		Component interface{}
+		Messages  int
+		Samples   int
+		Reset     HookReport
+		Flush     HookReport
+		Interrupt HookReport
+	}
+
+	// HookReport is the outcome of component hook in the run. Hook is
+	// not called if component doesn't implement it.
+	HookReport struct {
+		Called bool
+		Err    error
+	}
+)
+
+// Component returns the report of provided component. False is returned
+// if component is not a part of the run.
+func (r Report) Component(component interface{}) (ComponentReport, bool) {
+	for _, c := range r.Components {
+		if c.Component == component {
+			return c, true
+		}
+	}
+	return ComponentReport{}, false
+}
+
+// runReport collects the report of a single run.
+type runReport struct {
+	sync.Mutex
+	started    time.Time
+	components []*ComponentReport
+	pumps      []*linePump
+	errors     []error
+}
+
+// linePump is the report of line pump with its sample rate. Limited is
+// set by pump runner when signal is cut by the limit of the run.
+type linePump struct {
+	sampleRate signal.SampleRate
+	limited    bool
+	report     *ComponentReport
+}
+
+func newRunReport() *runReport {
+	return &runReport{
+		started: time.Now(),
+	}
+}
+
+// track returns runners of the chain that report their measurements and
+// hooks.
+func (r *runReport) track(c chain, fused runner.Fused) runner.Fused {
+	components := make(map[string]interface{}, len(c.components))
+	for component, id := range c.components {
+		components[id] = component
+	}
+	add := func(id string) *ComponentReport {
+		cr := &ComponentReport{
+			ID:        id,
+			Line:      c.uid,
+			Component: components[id],
+		}
+		r.components = append(r.components, cr)
+		return cr
+	}
+
+	cr := add(fused.Pump.ID)
+	lp := &linePump{sampleRate: c.sampleRate, report: cr}
+	r.pumps = append(r.pumps, lp)
+	fused.Pump.Limited = func() {
+		lp.limited = true
+	}
+	fused.Pump.Meter = cr.meter(fused.Pump.Meter)
+	fused.Pump.Hooks = cr.hooks(fused.Pump.Hooks)
+	for i := range fused.Processors {
+		cr := add(fused.Processors[i].ID)
+		fused.Processors[i].Meter = cr.meter(fused.Processors[i].Meter)
+		fused.Processors[i].Hooks = cr.hooks(fused.Processors[i].Hooks)
+	}
+	for i := range fused.Sinks {
+		cr := add(fused.Sinks[i].ID)
+		fused.Sinks[i].Meter = cr.meter(fused.Sinks[i].Meter)
+		fused.Sinks[i].Hooks = cr.hooks(fused.Sinks[i].Hooks)
+	}
+	return fused
+}
+
+// collect gathers all errors of components and calls fn with the report
+// once all components are done. Only the first error of each component
+// is passed further.
+func (r *runReport) collect(cancel <-chan struct{}, fn func(Report), errcList []<-chan error) []<-chan error {
+	var wg sync.WaitGroup
+	collected := make([]<-chan error, 0, len(errcList)+1)
+	for _, errc := range errcList {
+		out := make(chan error, 1)
+		wg.Add(1)
+		go func(in <-chan error) {
+			defer wg.Done()
+			defer close(out)
+			for err := range in {
+				r.add(err)
+				select {
+				case out <- err:
+				default:
+				}
+			}
+		}(errc)
+		collected = append(collected, out)
+	}
+	// closed once report is delivered
+	done := make(chan error)
+	go func() {
+		defer close(done)
+		wg.Wait()
+		fn(r.report(cancel))
+	}()
+	return append(collected, done)
+}
+
+// add records the error of component.
+func (r *runReport) add(err error) {
+	r.Lock()
+	r.errors = append(r.errors, err)
+	r.Unlock()
+}
+
+// report returns the report of completed run.
+func (r *runReport) report(cancel <-chan struct{}) Report {
+	r.Lock()
+	defer r.Unlock()
+	report := Report{
+		Wall:       time.Since(r.started),
+		Components: make([]ComponentReport, 0, len(r.components)),
+		Errors:     r.errors,
+	}
+	cancelled := runner.Cancelled(cancel)
+	switch {
+	case r.failed(cancelled):
+		report.Reason = StopError
+	case cancelled:
+		report.Reason = StopCancel
+	case r.limited():
+		report.Reason = StopLimit
+	default:
+		report.Reason = StopEOF
+	}
+	for _, p := range r.pumps {
+		if d := p.sampleRate.DurationOf(p.report.Samples); d > report.Duration {
+			report.Duration = d
+		}
+	}
+	for _, c := range r.components {
+		report.Components = append(report.Components, *c)
+	}
+	return report
+}
+
+// meter returns a meter that counts messages and samples of component.
+func (c *ComponentReport) meter(reset metric.ResetFunc) metric.ResetFunc {
+	return func(depth int) metric.MeasureFunc {
+		measure := reset(depth)
+		return func(size int, processing time.Duration) {
+			c.Messages++
+			c.Samples += size
+			measure(size, processing)
+		}
+	}
+}
+
+// hooks returns hooks that report their outcomes.
+func (c *ComponentReport) hooks(h runner.Hooks) runner.Hooks {
+	return runner.Hooks{
+		Flush:     c.Flush.hook(h.Flush),
+		Interrupt: c.Interrupt.hook(h.Interrupt),
+		Reset:     c.Reset.hook(h.Reset),
+	}
+}
+
+// hook returns a hook that reports its outcome. Nil is returned if hook
+// is not implemented.
+func (h *HookReport) hook(fn runner.Hook) runner.Hook {
+	if fn == nil {
+		return nil
+	}
+	return func(pipeID string) error {
+		err := fn(pipeID)
+		h.Called, h.Err = true, err
+		return err
+	}
+}
+
+// failed returns true if any component failed. Errors of interrupt
+// hooks are ignored if the run was cancelled. Must be called with lock
+// held.
+func (r *runReport) failed(cancelled bool) bool {
+	for _, err := range r.errors {
+		if !cancelled || !r.interruptErr(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// interruptErr returns true if error was returned by interrupt hook of
+// any component. Must be called with lock held.
+func (r *runReport) interruptErr(err error) bool {
+	for _, c := range r.components {
+		if c.Interrupt.Err != nil && errors.Is(err, c.Interrupt.Err) {
+			return true
+		}
+	}
+	return false
+}
+
+// limited returns true if signal of any pump was cut by the limit. Must
+// be called with lock held.
+func (r *runReport) limited() bool {
+	for _, p := range r.pumps {
+		if p.limited {
+			return true
+		}
+	}
+	return false
+}
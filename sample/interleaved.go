@@ -0,0 +1,91 @@
+package sample
+
+import (
+	"pipelined.dev/signal"
+)
+
+// InterFloat64 is an interleaved float64 signal.
+type InterFloat64 struct {
+	Data        []float64
+	NumChannels int
+}
+
+// InterFloat64Buffer returns an InterFloat64 buffer of specified
+// dimentions.
+func InterFloat64Buffer(numChannels, bufferSize int) InterFloat64 {
+	return InterFloat64{
+		Data:        make([]float64, numChannels*bufferSize),
+		NumChannels: numChannels,
+	}
+}
+
+// Size returns number of samples in single channel of this buffer.
+func (floats InterFloat64) Size() int {
+	if floats.NumChannels == 0 {
+		return 0
+	}
+	return len(floats.Data) / floats.NumChannels
+}
+
+// InterIntBuffer returns a signal.InterInt buffer of specified
+// dimentions and bit depth.
+func InterIntBuffer(numChannels, bufferSize int, bitDepth signal.BitDepth) signal.InterInt {
+	return signal.InterInt{
+		Data:        make([]int, numChannels*bufferSize),
+		NumChannels: numChannels,
+		BitDepth:    bitDepth,
+	}
+}
+
+// InterleaveFloat64 copies values from Float64 to InterFloat64 buffer.
+// Buffers must have the same dimensions.
+func InterleaveFloat64(dst InterFloat64, src signal.Float64) {
+	for i := range src {
+		for j, v := range src[i] {
+			dst.Data[j*dst.NumChannels+i] = v
+		}
+	}
+}
+
+// DeinterleaveFloat64 copies values from InterFloat64 to Float64 buffer.
+// Buffers must have the same dimensions.
+func DeinterleaveFloat64(dst signal.Float64, src InterFloat64) {
+	for i := range dst {
+		for j := range dst[i] {
+			dst[i][j] = src.Data[j*src.NumChannels+i]
+		}
+	}
+}
+
+// InterleaveInt copies values from signal.Int to signal.InterInt buffer.
+// Buffers must have the same dimensions and bit depth. Values are shifted
+// into unsigned range if dst is unsigned.
+func InterleaveInt(dst signal.InterInt, src signal.Int) {
+	shift := unsignedShift(dst)
+	for i := range src {
+		for j, v := range src[i] {
+			dst.Data[j*dst.NumChannels+i] = v + shift
+		}
+	}
+}
+
+// DeinterleaveInt copies values from signal.InterInt to signal.Int
+// buffer. Buffers must have the same dimensions and bit depth. Values are
+// shifted into signed range if src is unsigned.
+func DeinterleaveInt(dst signal.Int, src signal.InterInt) {
+	shift := unsignedShift(src)
+	for i := range dst {
+		for j := range dst[i] {
+			dst[i][j] = src.Data[j*src.NumChannels+i] - shift
+		}
+	}
+}
+
+// unsignedShift returns the offset of unsigned values of buffer. It's the
+// same offset that signal package uses for conversions.
+func unsignedShift(ints signal.InterInt) int {
+	if !ints.Unsigned {
+		return 0
+	}
+	return int(resolution(ints.BitDepth))
+}
@@ -0,0 +1,230 @@
+// Package sample provides sample formats that can be transported
+// through the pipe and conversions between them.
+package sample
+
+import (
+	"fmt"
+
+	"pipelined.dev/signal"
+)
+
+// Format identifies the type of buffer that carries the signal.
+type Format uint8
+
+const (
+	// FormatFloat64 is carried by signal.Float64 buffers. It's the
+	// default format of the pipe.
+	FormatFloat64 Format = iota
+	// FormatFloat32 is carried by Float32 buffers.
+	FormatFloat32
+	// FormatInt8 is carried by signal.Int buffers with 8 bit values.
+	FormatInt8
+	// FormatInt16 is carried by signal.Int buffers with 16 bit values.
+	FormatInt16
+	// FormatInt24 is carried by signal.Int buffers with 24 bit values.
+	FormatInt24
+	// FormatInt32 is carried by signal.Int buffers with 32 bit values.
+	FormatInt32
+)
+
+// Interleaved is a flag of format that is carried by interleaved
+// buffers. Float64 format is carried by InterFloat64 buffers and integer
+// formats are carried by signal.InterInt buffers. Float32 format cannot
+// be interleaved.
+const Interleaved Format = 1 << 7
+
+// IntFormat returns the format of signal.Int buffers with provided bit
+// depth.
+func IntFormat(bitDepth signal.BitDepth) (Format, error) {
+	switch bitDepth {
+	case signal.BitDepth8:
+		return FormatInt8, nil
+	case signal.BitDepth16:
+		return FormatInt16, nil
+	case signal.BitDepth24:
+		return FormatInt24, nil
+	case signal.BitDepth32:
+		return FormatInt32, nil
+	}
+	return 0, fmt.Errorf("unsupported bit depth: %v", bitDepth)
+}
+
+// IsInt returns true if format is carried by signal.Int or
+// signal.InterInt buffers.
+func (f Format) IsInt() bool {
+	f = f.Planar()
+	return f >= FormatInt8 && f <= FormatInt32
+}
+
+// IsInterleaved returns true if format is carried by interleaved buffers.
+func (f Format) IsInterleaved() bool {
+	return f&Interleaved != 0
+}
+
+// Planar returns the format without interleaved flag.
+func (f Format) Planar() Format {
+	return f &^ Interleaved
+}
+
+// BitDepth returns the bit depth of integer formats. Zero is returned
+// for float formats.
+func (f Format) BitDepth() signal.BitDepth {
+	switch f.Planar() {
+	case FormatInt8:
+		return signal.BitDepth8
+	case FormatInt16:
+		return signal.BitDepth16
+	case FormatInt24:
+		return signal.BitDepth24
+	case FormatInt32:
+		return signal.BitDepth32
+	}
+	return 0
+}
+
+func (f Format) String() string {
+	var name string
+	switch p := f.Planar(); {
+	case p == FormatFloat64:
+		name = "float64"
+	case p == FormatFloat32:
+		name = "float32"
+	case p.IsInt():
+		name = fmt.Sprintf("int%d", f.BitDepth())
+	default:
+		return fmt.Sprintf("Format(%d)", uint8(f))
+	}
+	if f.IsInterleaved() {
+		return name + " interleaved"
+	}
+	return name
+}
+
+// Float32 is a non-interleaved float32 signal.
+type Float32 [][]float32
+
+// Float32Buffer returns a Float32 buffer of specified dimentions.
+func Float32Buffer(numChannels, bufferSize int) Float32 {
+	result := make([][]float32, numChannels)
+	for i := range result {
+		result[i] = make([]float32, bufferSize)
+	}
+	return result
+}
+
+// NumChannels returns number of channels in this sample slice.
+func (floats Float32) NumChannels() int {
+	return len(floats)
+}
+
+// Size returns number of samples in single block in this sample slice.
+func (floats Float32) Size() int {
+	if floats.NumChannels() == 0 {
+		return 0
+	}
+	return len(floats[0])
+}
+
+// IntBuffer returns a signal.Int buffer of specified dimentions.
+func IntBuffer(numChannels, bufferSize int) signal.Int {
+	result := make([][]int, numChannels)
+	for i := range result {
+		result[i] = make([]int, bufferSize)
+	}
+	return result
+}
+
+// IntSize returns number of samples in single block of signal.Int buffer.
+func IntSize(ints signal.Int) int {
+	if len(ints) == 0 {
+		return 0
+	}
+	return len(ints[0])
+}
+
+// resolution returns a half resolution for a passed bit depth. It's the
+// same value that signal package uses for conversions.
+func resolution(bitDepth signal.BitDepth) float64 {
+	if bitDepth == 0 {
+		return 1
+	}
+	return float64(int(1)<<(bitDepth-1) - 1)
+}
+
+// Float64ToFloat32 copies values from Float64 to Float32 buffer. Buffers
+// must have the same dimensions.
+func Float64ToFloat32(dst Float32, src signal.Float64) {
+	for i := range src {
+		for j, v := range src[i] {
+			dst[i][j] = float32(v)
+		}
+	}
+}
+
+// Float32ToFloat64 copies values from Float32 to Float64 buffer. Buffers
+// must have the same dimensions.
+func Float32ToFloat64(dst signal.Float64, src Float32) {
+	for i := range src {
+		for j, v := range src[i] {
+			dst[i][j] = float64(v)
+		}
+	}
+}
+
+// Float64ToInt copies values from Float64 to signal.Int buffer of
+// provided bit depth. Buffers must have the same dimensions.
+func Float64ToInt(dst signal.Int, src signal.Float64, bitDepth signal.BitDepth) {
+	res := resolution(bitDepth)
+	for i := range src {
+		for j, v := range src[i] {
+			dst[i][j] = int(v * res)
+		}
+	}
+}
+
+// IntToFloat64 copies values from signal.Int buffer of provided bit depth
+// to Float64 buffer. Buffers must have the same dimensions.
+func IntToFloat64(dst signal.Float64, src signal.Int, bitDepth signal.BitDepth) {
+	res := resolution(bitDepth)
+	for i := range src {
+		for j, v := range src[i] {
+			dst[i][j] = float64(v) / res
+		}
+	}
+}
+
+// Float32ToInt copies values from Float32 to signal.Int buffer of
+// provided bit depth. Buffers must have the same dimensions.
+func Float32ToInt(dst signal.Int, src Float32, bitDepth signal.BitDepth) {
+	res := float32(resolution(bitDepth))
+	for i := range src {
+		for j, v := range src[i] {
+			dst[i][j] = int(v * res)
+		}
+	}
+}
+
+// IntToFloat32 copies values from signal.Int buffer of provided bit depth
+// to Float32 buffer. Buffers must have the same dimensions.
+func IntToFloat32(dst Float32, src signal.Int, bitDepth signal.BitDepth) {
+	res := float32(resolution(bitDepth))
+	for i := range src {
+		for j, v := range src[i] {
+			dst[i][j] = float32(v) / res
+		}
+	}
+}
+
+// IntToInt copies values between signal.Int buffers of different bit
+// depths. Buffers must have the same dimensions.
+func IntToInt(dst, src signal.Int, from, to signal.BitDepth) {
+	for i := range src {
+		for j, v := range src[i] {
+			if to >= from {
+				dst[i][j] = v << (to - from)
+			} else {
+				dst[i][j] = v >> (from - to)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package sample_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipelined.dev/pipe/sample"
+	"pipelined.dev/signal"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		bitDepth signal.BitDepth
+		format   sample.Format
+		str      string
+		err      bool
+	}{
+		{bitDepth: signal.BitDepth8, format: sample.FormatInt8, str: "int8"},
+		{bitDepth: signal.BitDepth16, format: sample.FormatInt16, str: "int16"},
+		{bitDepth: signal.BitDepth24, format: sample.FormatInt24, str: "int24"},
+		{bitDepth: signal.BitDepth32, format: sample.FormatInt32, str: "int32"},
+		{bitDepth: 12, err: true},
+	}
+	for _, test := range tests {
+		format, err := sample.IntFormat(test.bitDepth)
+		if test.err {
+			assert.Error(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, test.format, format)
+		assert.True(t, format.IsInt())
+		assert.Equal(t, test.bitDepth, format.BitDepth())
+		assert.Equal(t, test.str, format.String())
+	}
+	assert.False(t, sample.FormatFloat32.IsInt())
+	assert.Equal(t, signal.BitDepth(0), sample.FormatFloat64.BitDepth())
+	assert.Equal(t, "float32", sample.FormatFloat32.String())
+}
+
+func TestConversions(t *testing.T) {
+	floats := signal.Float64{{0, 0.5, -0.5, 1}, {-1, 0.25, 0, 0}}
+	numChannels, size := floats.NumChannels(), floats.Size()
+
+	// float64 -> float32 -> float64
+	f32 := sample.Float32Buffer(numChannels, size)
+	sample.Float64ToFloat32(f32, floats)
+	assert.Equal(t, numChannels, f32.NumChannels())
+	assert.Equal(t, size, f32.Size())
+	f64 := signal.Float64Buffer(numChannels, size)
+	sample.Float32ToFloat64(f64, f32)
+	assert.Equal(t, floats, f64)
+
+	// float64 -> int16 -> float32 -> int24 -> int16 -> float64
+	ints := sample.IntBuffer(numChannels, size)
+	sample.Float64ToInt(ints, floats, signal.BitDepth16)
+	assert.Equal(t, size, sample.IntSize(ints))
+	assert.Equal(t, []int{0, 16383, -16383, 32767}, ints[0])
+	sample.IntToFloat32(f32, ints, signal.BitDepth16)
+	ints24 := sample.IntBuffer(numChannels, size)
+	sample.Float32ToInt(ints24, f32, signal.BitDepth24)
+	sample.IntToInt(ints, ints24, signal.BitDepth24, signal.BitDepth16)
+	sample.IntToInt(ints24, ints, signal.BitDepth16, signal.BitDepth24)
+	sample.IntToFloat64(f64, ints, signal.BitDepth16)
+	for i := range floats {
+		assert.InDeltaSlice(t, floats[i], f64[i], 0.001)
+	}
+	assert.Equal(t, 0, sample.IntSize(nil))
+	assert.Equal(t, 0, sample.Float32(nil).Size())
+}
+
+func TestInterleaved(t *testing.T) {
+	interleaved := sample.FormatInt16 | sample.Interleaved
+	assert.True(t, interleaved.IsInt())
+	assert.True(t, interleaved.IsInterleaved())
+	assert.False(t, sample.FormatInt16.IsInterleaved())
+	assert.Equal(t, sample.FormatInt16, interleaved.Planar())
+	assert.Equal(t, signal.BitDepth16, interleaved.BitDepth())
+	assert.Equal(t, "int16 interleaved", interleaved.String())
+	assert.Equal(t, "float64 interleaved", (sample.FormatFloat64 | sample.Interleaved).String())
+
+	floats := signal.Float64{{0, 0.5, 1}, {-1, -0.5, 0}}
+	numChannels, size := floats.NumChannels(), floats.Size()
+
+	// float64 -> interleaved float64 -> float64
+	inter := sample.InterFloat64Buffer(numChannels, size)
+	sample.InterleaveFloat64(inter, floats)
+	assert.Equal(t, size, inter.Size())
+	assert.Equal(t, []float64{0, -1, 0.5, -0.5, 1, 0}, inter.Data)
+	f64 := signal.Float64Buffer(numChannels, size)
+	sample.DeinterleaveFloat64(f64, inter)
+	assert.Equal(t, floats, f64)
+
+	// int -> interleaved int -> int
+	ints := signal.Int{{1, 2, 3}, {-1, -2, -3}}
+	interInts := sample.InterIntBuffer(numChannels, size, signal.BitDepth16)
+	sample.InterleaveInt(interInts, ints)
+	assert.Equal(t, size, interInts.Size())
+	assert.Equal(t, []int{1, -1, 2, -2, 3, -3}, interInts.Data)
+	result := sample.IntBuffer(numChannels, size)
+	sample.DeinterleaveInt(result, interInts)
+	assert.Equal(t, ints, result)
+
+	// unsigned interleaved int -> int -> unsigned interleaved int
+	unsigned := sample.InterIntBuffer(numChannels, size, signal.BitDepth8)
+	unsigned.Unsigned = true
+	sample.InterleaveInt(unsigned, ints)
+	assert.Equal(t, []int{128, 126, 129, 125, 130, 124}, unsigned.Data)
+	sample.DeinterleaveInt(result, unsigned)
+	assert.Equal(t, ints, result)
+	assert.Equal(t, 0, sample.InterFloat64{}.Size())
+}
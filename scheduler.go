@@ -0,0 +1,35 @@
+package pipe
+
+import (
+	"pipelined.dev/pipe/internal/runner"
+)
+
+// Scheduler executes lines of attached pipes with a fixed number of
+// worker goroutines. Lines are served in turns, each line processes the
+// number of buffers equal to the priority of its pipe per turn. Use
+// NewScheduled to attach pipes. Pipes keep their Run, Pause, Resume and
+// Close semantics. Lines of paused pipe don't occupy workers, they are
+// served again when pipe is resumed. Components don't need goroutines,
+// errors of lines are collected without them too, but state of each
+// pipe is still handled by its own goroutine.
+type Scheduler struct {
+	s       *runner.Scheduler
+	workers *runner.Workers // workers of channel processors
+}
+
+// NewScheduler starts a scheduler with provided number of workers.
+// Channel processors of attached pipes share the same number of
+// goroutines.
+func NewScheduler(workers int) *Scheduler {
+	return &Scheduler{
+		s:       runner.NewScheduler(workers),
+		workers: runner.NewWorkers(workers),
+	}
+}
+
+// Close stops the workers of scheduler. It blocks until all runs of
+// attached pipes are done, so pipes should be closed first. Attached
+// pipes cannot be run after scheduler is closed.
+func (s *Scheduler) Close() {
+	s.s.Close()
+}
@@ -0,0 +1,336 @@
+package pipe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"pipelined.dev/pipe/internal/pool"
+	"pipelined.dev/pipe/internal/runner"
+	"pipelined.dev/pipe/internal/state"
+	"pipelined.dev/pipe/metric"
+)
+
+// Stepper executes lines deterministically on the calling goroutine.
+// Lines are fused and each Step passes a single buffer through every
+// running line in the order lines were provided. Params pushed before
+// the step are delivered with its buffers. Run, Pause, Resume and Close
+// are handled synchronously, so tests can assert exact interleavings of
+// buffers, params and state events. Stepper is not safe for concurrent
+// use.
+type Stepper struct {
+	chains           []*chain
+	chainByComponent map[string]*chain
+	recorder         metric.Recorder
+	state            stepperState
+	bufferSize       int
+	options          []RunOption
+	cancel           chan struct{}
+	tracker          *pool.Tracker
+	report           *runReport // nil if run is not reported
+	reportFn         func(Report)
+	steps            []func() bool // nil if line is done
+	errs             []<-chan error
+}
+
+// stepperState is the state of stepper.
+type stepperState string
+
+const (
+	stepperReady   stepperState = "ready"
+	stepperRunning stepperState = "running"
+	stepperPaused  stepperState = "paused"
+	stepperClosed  stepperState = "closed"
+)
+
+// NewStepper creates a new stepper for provided lines.
+// Returned stepper is in ready state.
+func NewStepper(ls ...*Line) (*Stepper, error) {
+	return Config{}.NewStepper(ls...)
+}
+
+// NewStepper creates a new stepper with the config. See NewStepper for
+// details.
+func (config Config) NewStepper(ls ...*Line) (*Stepper, error) {
+	s := Stepper{
+		chains:           make([]*chain, 0, len(ls)),
+		chainByComponent: make(map[string]*chain),
+		recorder:         config.recorder(),
+		state:            stepperReady,
+	}
+	for _, l := range ls {
+		c, err := bindLine(l, s.recorder)
+		if err != nil {
+			s.dropMetrics()
+			return nil, fmt.Errorf("error binding line: %w", err)
+		}
+		s.chains = append(s.chains, &c)
+		for _, componentID := range c.components {
+			s.chainByComponent[componentID] = &c
+		}
+	}
+	return &s, nil
+}
+
+// ComponentID finds id of the component within stepper.
+func (s *Stepper) ComponentID(component interface{}) (id string, ok bool) {
+	for _, c := range s.chains {
+		if id, ok = c.components[component]; ok {
+			break
+		}
+	}
+	return id, ok
+}
+
+// Push new params into stepper. Params are delivered with the buffer
+// of the next step.
+func (s *Stepper) Push(id string, paramFuncs ...func()) {
+	if c, ok := s.chainByComponent[id]; ok {
+		c.params = c.params.Add(id, paramFuncs...)
+	}
+}
+
+// Run starts a new run. Reset hooks of all components are called with
+// the first step. Options are applied to this run only.
+func (s *Stepper) Run(bufferSize int, options ...RunOption) error {
+	if s.state != stepperReady {
+		return s.invalid("run")
+	}
+	s.bufferSize, s.options = bufferSize, options
+	s.start()
+	return nil
+}
+
+// Step passes a single buffer through each running line. It returns
+// false when the run is done: all lines are done or one of them failed.
+// Once the run is done, stepper is in ready state.
+func (s *Stepper) Step() (bool, error) {
+	if s.state != stepperRunning {
+		return false, s.invalid("step")
+	}
+	running := false
+	for i, step := range s.steps {
+		if step == nil {
+			continue
+		}
+		if step() {
+			running = true
+			continue
+		}
+		s.steps[i] = nil
+		if err := s.done(i); err != nil {
+			// cancel other lines on error
+			s.stop()
+			s.state = stepperReady
+			return false, joinErrors(err, s.finish())
+		}
+	}
+	if running {
+		return true, nil
+	}
+	s.state = stepperReady
+	return false, s.finish()
+}
+
+// done returns the error of finished line. Error is added to the report
+// of the run.
+func (s *Stepper) done(i int) error {
+	err := <-s.errs[i]
+	if err != nil && s.report != nil {
+		s.report.add(err)
+	}
+	return err
+}
+
+// finish checks buffers of the finished run and delivers its report.
+// Errors of buffers are returned.
+func (s *Stepper) finish() error {
+	err := s.track()
+	if err != nil && s.report != nil {
+		s.report.add(err)
+	}
+	s.deliver()
+	return err
+}
+
+// deliver calls the report function with the report of the run.
+func (s *Stepper) deliver() {
+	if s.report != nil {
+		s.reportFn(s.report.report(s.cancel))
+		s.report = nil
+	}
+}
+
+// track returns the errors of buffers detected by tracker of the run.
+// Misuses of buffers are checked before leaks.
+func (s *Stepper) track() error {
+	if s.tracker == nil {
+		return nil
+	}
+	if err := s.tracker.Err(); err != nil {
+		return err
+	}
+	return s.tracker.Leaks()
+}
+
+// Pause stops stepping of the run. The run is kept, so no hooks are
+// called and positions of buffers continue after Resume.
+func (s *Stepper) Pause() error {
+	if s.state != stepperRunning {
+		return s.invalid("pause")
+	}
+	s.state = stepperPaused
+	return nil
+}
+
+// Resume continues stepping of the paused run.
+func (s *Stepper) Resume() error {
+	if s.state != stepperPaused {
+		return s.invalid("resume")
+	}
+	s.state = stepperRunning
+	return nil
+}
+
+// Close interrupts the run if stepper is running or paused. Metrics of
+// stepper components are dropped. Closed stepper cannot be used
+// anymore.
+func (s *Stepper) Close() error {
+	if s.state == stepperClosed {
+		return s.invalid("close")
+	}
+	var err error
+	if s.state == stepperRunning || s.state == stepperPaused {
+		err = s.stop()
+		s.deliver()
+	}
+	s.dropMetrics()
+	s.state = stepperClosed
+	return err
+}
+
+// dropMetrics drops metrics of all components of stepper.
+func (s *Stepper) dropMetrics() {
+	for _, c := range s.chains {
+		s.recorder.Drop(c.uid)
+	}
+}
+
+// start prepares runners of all lines.
+func (s *Stepper) start() {
+	config := newRunConfig(s.options)
+	s.tracker = nil
+	if config.debug {
+		s.tracker = pool.NewTracker()
+	}
+	s.cancel = make(chan struct{})
+	s.report, s.reportFn = nil, config.report
+	if config.report != nil {
+		s.report = newRunReport()
+	}
+	s.steps = make([]func() bool, len(s.chains))
+	s.errs = make([]<-chan error, len(s.chains))
+	workers := runner.NewWorkers(config.workers)
+	for i, c := range s.chains {
+		r, pools := c.runners(s.bufferSize, s.recorder, config, s.tracker, workers)
+		if s.report != nil {
+			r = s.report.track(*c, r)
+		}
+		s.steps[i], s.errs[i] = r.Start(pools, c.uid, s.cancel, s.receive(c))
+	}
+	s.state = stepperRunning
+}
+
+// stop cancels the run and steps all lines until they are done. The
+// first error is returned.
+func (s *Stepper) stop() error {
+	close(s.cancel)
+	var first error
+	for i, step := range s.steps {
+		if step == nil {
+			continue
+		}
+		for step() {
+		}
+		s.steps[i] = nil
+		if err := s.done(i); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// receive returns a function that creates new messages for the chain
+// with pushed params.
+func (s *Stepper) receive(c *chain) func() (runner.Message, bool) {
+	cancel := s.cancel
+	return func() (runner.Message, bool) {
+		select {
+		case <-cancel:
+			return runner.Message{}, false
+		default:
+		}
+		m := runner.Message{PipeID: c.uid}
+		if len(c.params) > 0 {
+			m.Params = c.params
+			c.params = nil
+		}
+		return m, true
+	}
+}
+
+// errorList is a list of errors that happened in the same run. It
+// matches any of its errors.
+type errorList []error
+
+func (e errorList) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any error in the list matches the target.
+func (e errorList) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error in the list that matches the target.
+func (e errorList) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinErrors returns an error that combines non-nil errors. It returns
+// nil if there are no errors and the error itself if it's the only one.
+func joinErrors(errs ...error) error {
+	var list errorList
+	for _, err := range errs {
+		if err != nil {
+			list = append(list, err)
+		}
+	}
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	}
+	return list
+}
+
+// invalid returns an error for event that cannot be handled in the
+// current state.
+func (s *Stepper) invalid(event string) error {
+	return fmt.Errorf("%s during %s: %w", event, s.state, state.ErrInvalidState)
+}